@@ -0,0 +1,63 @@
+package orderedmap
+
+// ChainedStringMap looks up keys in a StringMap and falls back to another StringMap
+// Both maps are referenced rather than copied, so either can still be modified independently
+type ChainedStringMap struct {
+	primary  *StringMap
+	fallback *StringMap
+}
+
+// WithFallback returns a ChainedStringMap consulting m first and fallback for keys absent in m
+func (m *StringMap) WithFallback(fallback *StringMap) ChainedStringMap {
+	return ChainedStringMap{primary: m, fallback: fallback}
+}
+
+// Value returns the value for key from the primary map, or else from the fallback
+func (c ChainedStringMap) Value(key string) (string, bool) {
+	if value, ok := c.primary.Value(key); ok {
+		return value, true
+	}
+	if c.fallback == nil {
+		return "", false
+	}
+	return c.fallback.Value(key)
+}
+
+// Has reports whether key is present in either map
+func (c ChainedStringMap) Has(key string) bool {
+	_, ok := c.Value(key)
+	return ok
+}
+
+// Keys returns the keys of the primary map in order, followed by the keys which only the
+// fallback has, in order of the fallback
+func (c ChainedStringMap) Keys() []string {
+	keys := c.primary.Keys()
+	if c.fallback == nil {
+		return keys
+	}
+
+	for _, key := range c.fallback.keys {
+		if _, exists := c.primary.values[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Len returns the number of distinct keys in both maps, which is the number of keys Keys returns
+func (c ChainedStringMap) Len() int {
+	n := c.primary.Len()
+	if c.fallback == nil {
+		return n
+	}
+
+	for _, key := range c.fallback.keys {
+		if _, exists := c.primary.values[key]; !exists {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,68 @@
+package orderedmap_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestChainedStringMap(t *testing.T) {
+	var defaults, local StringMap
+	defaults.Set("host", "localhost")
+	defaults.Set("port", "80")
+	defaults.Set("debug", "false")
+	local.Set("debug", "true")
+	local.Set("user", "root")
+
+	chained := local.WithFallback(&defaults)
+
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"debug", "true", true},
+		{"user", "root", true},
+		{"port", "80", true},
+		{"notexist", "", false},
+	}
+	for _, test := range tests {
+		if value, ok := chained.Value(test.key); value != test.value || ok != test.ok {
+			t.Errorf("expected value for key %q to be %q, %t, got %q, %t", test.key, test.value, test.ok, value, ok)
+		}
+		if chained.Has(test.key) != test.ok {
+			t.Errorf("expected Has(%q) to be %t", test.key, test.ok)
+		}
+	}
+
+	if keys := fmt.Sprint(chained.Keys()); keys != "[debug user host port]" {
+		t.Errorf("expected keys [debug user host port], got %s", keys)
+	}
+	if chained.Len() != 4 {
+		t.Errorf("expected 4 keys, got %d", chained.Len())
+	}
+
+	// Layers remain independently updatable
+	defaults.Set("timeout", "5s")
+	local.Set("port", "8080")
+	if value, _ := chained.Value("timeout"); value != "5s" {
+		t.Errorf("expected fallback update to be visible, got %q", value)
+	}
+	if value, _ := chained.Value("port"); value != "8080" {
+		t.Errorf("expected local update to be visible, got %q", value)
+	}
+}
+
+func TestChainedStringMap_NilFallback(t *testing.T) {
+	var local StringMap
+	local.Set("user", "root")
+
+	chained := local.WithFallback(nil)
+	if _, ok := chained.Value("host"); ok {
+		t.Errorf("expected no value for key %q", "host")
+	}
+	if chained.Len() != 1 || len(chained.Keys()) != 1 {
+		t.Errorf("expected 1 key, got %d; %q", chained.Len(), chained.Keys())
+	}
+}
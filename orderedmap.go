@@ -0,0 +1,196 @@
+package orderedmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+var _ json.Marshaler = (*OrderedMap[string, int])(nil)
+var _ json.Unmarshaler = (*OrderedMap[string, int])(nil)
+
+// OrderedMap represents a map of K to V which maintains its order when marshaled to/from JSON
+// Keys are encoded as JSON object keys following the rules encoding/json applies to maps: K must
+// be a string or integer type or implement encoding.TextMarshaler, and encoding.TextUnmarshaler
+// for unmarshaling. Other key types fail to marshal
+// Like the built-in map, this type is not concurrency safe
+type OrderedMap[K comparable, V any] struct {
+	keys   []K
+	values map[K]V
+}
+
+// Set sets a key to a value
+// If a key already exists it is overwritten
+func (m *OrderedMap[K, V]) Set(key K, value V) {
+	if m.values == nil {
+		m.values = make(map[K]V)
+	}
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes a key from the map, preserving the order of the remaining keys
+// Locating the key in the order takes linear time. Deleting a key which does not exist is a no-op
+func (m *OrderedMap[K, V]) Delete(key K) {
+	if _, exists := m.values[key]; !exists {
+		return
+	}
+	delete(m.values, key)
+
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order
+func (m OrderedMap[K, V]) Keys() []K {
+	keys := make([]K, len(m.keys))
+	copy(keys, m.keys)
+
+	return keys
+}
+
+// Value returns the value for key
+func (m OrderedMap[K, V]) Value(key K) (V, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Len returns the number of entries
+func (m OrderedMap[K, V]) Len() int { return len(m.keys) }
+
+// MarshalJSON implements json.Marshaler
+func (m OrderedMap[K, V]) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("{")
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		// marshal key
+		sKey, err := marshalKey(key)
+		if err != nil {
+			return nil, err
+		}
+		bKey, _ := json.Marshal(sKey)
+		buf.Write(bKey)
+		buf.WriteString(":")
+
+		// marshal value
+		bVal, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(bVal)
+	}
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *OrderedMap[K, V]) UnmarshalJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+
+	// start of object
+	if t, err := d.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return errors.New("looking for beginning of object")
+	}
+
+	// key/value pairs
+	for d.More() {
+		tKey, err := d.Token()
+		if err != nil {
+			return err
+		}
+		key, err := unmarshalKey[K](tKey.(string))
+		if err != nil {
+			return err
+		}
+
+		var value V
+		if err = d.Decode(&value); err != nil {
+			return err
+		}
+
+		m.Set(key, value)
+	}
+
+	// end of object
+	if t, err := d.Token(); t != json.Delim('}') {
+		return err
+	}
+
+	// end of input
+	if _, err := d.Token(); err != io.EOF {
+		return errors.New("expected end of JSON input")
+	}
+	return nil
+}
+
+// marshalKey returns the JSON object key for key
+func marshalKey[K comparable](key K) (string, error) {
+	rv := reflect.ValueOf(&key).Elem()
+	if rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	if tm, ok := any(key).(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported key type %s", rv.Type())
+}
+
+// unmarshalKey returns the key for the JSON object key s
+func unmarshalKey[K comparable](s string) (K, error) {
+	var key K
+	// like encoding/json, a TextUnmarshaler takes precedence over the string kind when decoding keys
+	if tu, ok := any(&key).(encoding.TextUnmarshaler); ok {
+		err := tu.UnmarshalText([]byte(s))
+		return key, err
+	}
+	rv := reflect.ValueOf(&key).Elem()
+	if rv.Kind() == reflect.String {
+		rv.SetString(s)
+		return key, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			return key, fmt.Errorf("invalid key %q for type %s", s, rv.Type())
+		}
+		rv.SetInt(n)
+		return key, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			return key, fmt.Errorf("invalid key %q for type %s", s, rv.Type())
+		}
+		rv.SetUint(n)
+		return key, nil
+	}
+	return key, fmt.Errorf("unsupported key type %s", rv.Type())
+}
@@ -0,0 +1,175 @@
+package orderedmap_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+type item struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// point is a comparable key type which marshals to text
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *point) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+// lowerKey is a string key type which is lowercased when unmarshaled from text
+type lowerKey string
+
+func (k *lowerKey) UnmarshalText(b []byte) error {
+	*k = lowerKey(strings.ToLower(string(b)))
+	return nil
+}
+
+func TestOrderedMap(t *testing.T) {
+	var orderedmap OrderedMap[int, item]
+	orderedmap.Set(3, item{"three", 0})
+	orderedmap.Set(1, item{"one", 1})
+	orderedmap.Set(2, item{"two", 2})
+	orderedmap.Set(3, item{"three", 3})
+	orderedmap.Delete(1)
+	orderedmap.Delete(4)
+
+	if keys := fmt.Sprint(orderedmap.Keys()); keys != "[3 2]" {
+		t.Errorf("expected keys [3 2], got %s", keys)
+	}
+	if orderedmap.Len() != 2 {
+		t.Errorf("expected 2 items, got %d", orderedmap.Len())
+	}
+	if value, ok := orderedmap.Value(3); !ok || value != (item{"three", 3}) {
+		t.Errorf("expected value for key 3 to be overwritten, got %v, %t", value, ok)
+	}
+	if _, ok := orderedmap.Value(1); ok {
+		t.Errorf("expected key 1 to be deleted")
+	}
+
+	// Keys returns a copy
+	orderedmap.Keys()[0] = 9
+	if keys := fmt.Sprint(orderedmap.Keys()); keys != "[3 2]" {
+		t.Errorf("expected keys [3 2], got %s", keys)
+	}
+}
+
+func TestOrderedMap_MarshalJSON(t *testing.T) {
+	var orderedmap OrderedMap[int, item]
+	orderedmap.Set(3, item{"three", 3})
+	orderedmap.Set(-1, item{"minus one", -1})
+
+	actually, err := json.Marshal(orderedmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte(`{"3":{"name":"three","count":3},"-1":{"name":"minus one","count":-1}}`)
+	if !bytes.Equal(actually, expected) {
+		t.Errorf("expected json %s, got %s", expected, actually)
+	}
+}
+
+func TestOrderedMap_MarshalJSONTextKeys(t *testing.T) {
+	var orderedmap OrderedMap[point, string]
+	orderedmap.Set(point{2, 3}, "b")
+	orderedmap.Set(point{0, 1}, "a")
+
+	b, err := json.Marshal(orderedmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"2,3":"b","0,1":"a"}`; string(b) != expected {
+		t.Errorf("expected json %s, got %s", expected, b)
+	}
+
+	var decoded OrderedMap[point, string]
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fmt.Sprint(decoded.Keys()); keys != "[{2 3} {0 1}]" {
+		t.Errorf("expected keys [{2 3} {0 1}], got %s", keys)
+	}
+}
+
+func TestOrderedMap_UnmarshalJSONTextStringKeys(t *testing.T) {
+	input := []byte(`{"B":"2","a":"1"}`)
+
+	var orderedmap OrderedMap[lowerKey, string]
+	if err := json.Unmarshal(input, &orderedmap); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fmt.Sprint(orderedmap.Keys()); keys != "[b a]" {
+		t.Errorf("expected keys [b a], got %s", keys)
+	}
+
+	// Same keys as encoding/json decodes into a built-in map
+	var builtin map[lowerKey]string
+	if err := json.Unmarshal(input, &builtin); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range orderedmap.Keys() {
+		if _, ok := builtin[key]; !ok {
+			t.Errorf("expected key %q in built-in map %v", key, builtin)
+		}
+	}
+}
+
+func TestOrderedMap_MarshalJSONUnsupportedKey(t *testing.T) {
+	var orderedmap OrderedMap[item, string]
+	orderedmap.Set(item{"one", 1}, "a")
+
+	if _, err := json.Marshal(orderedmap); err == nil {
+		t.Errorf("expected error for unsupported key type")
+	}
+}
+
+func TestOrderedMap_UnmarshalJSON(t *testing.T) {
+	var orderedmap OrderedMap[uint8, []string]
+	err := json.Unmarshal([]byte(`{"3":["c"],"1":["a","b"],"2":null}`), &orderedmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := fmt.Sprint(orderedmap.Keys()); keys != "[3 1 2]" {
+		t.Errorf("expected keys [3 1 2], got %s", keys)
+	}
+	if value, _ := orderedmap.Value(1); fmt.Sprint(value) != "[a b]" {
+		t.Errorf("expected value for key 1 to be [a b], got %v", value)
+	}
+}
+
+func TestOrderedMap_UnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"empty input", []byte("")},
+		{"json null value", []byte("null")},
+		{"invalid key", []byte(`{"one":1}`)},
+		{"key out of range", []byte(`{"256":1}`)},
+		{"invalid value type", []byte(`{"1":"one"}`)},
+		{"invalid end of object", []byte(`{"1": 1 `)},
+		{"trailing data", []byte(`{"1": 1 },`)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var orderedmap OrderedMap[uint8, int]
+			if err := orderedmap.UnmarshalJSON(test.input); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
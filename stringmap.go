@@ -2,95 +2,1901 @@ package orderedmap
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"html"
 	"io"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 )
 
 var _ json.Marshaler = (*StringMap)(nil)
 var _ json.Unmarshaler = (*StringMap)(nil)
 var _ sort.Interface = (*StringMap)(nil)
 
+// Entry is a single key/value pair of a StringMap
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// Pair is an alias of Entry
+type Pair = Entry
+
+// KeysError is returned by ValidateKeys
+type KeysError struct {
+	Missing    []string // required keys that are absent, as ordered in the list of required keys
+	Unexpected []string // keys that are not allowed, in order of the map
+}
+
+func (e *KeysError) Error() string {
+	var msg []string
+	if len(e.Missing) > 0 {
+		msg = append(msg, fmt.Sprintf("missing required keys %q", e.Missing))
+	}
+	if len(e.Unexpected) > 0 {
+		msg = append(msg, fmt.Sprintf("unexpected keys %q", e.Unexpected))
+	}
+
+	return strings.Join(msg, "; ")
+}
+
+// ValueError is a value that failed validation by ValidateValues
+type ValueError struct {
+	Key string
+	Err error
+}
+
+func (e ValueError) Error() string {
+	return fmt.Sprintf("invalid value for key %q: %v", e.Key, e.Err)
+}
+
+// ValuesError is returned by ValidateValues, listing every invalid value in order of the map
+type ValuesError []ValueError
+
+func (e ValuesError) Error() string {
+	msg := make([]string, len(e))
+	for i, err := range e {
+		msg[i] = err.Error()
+	}
+
+	return strings.Join(msg, "; ")
+}
+
+// ChangeKind is the kind of a Change
+type ChangeKind int
+
+const (
+	// Added is a key only present in the other map
+	Added ChangeKind = iota + 1
+	// Removed is a key only present in the receiving map
+	Removed
+	// Modified is a key present in both maps with a different value
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a single difference between two maps as returned by Changelog
+// OldValue is empty for added keys, NewValue is empty for removed keys
+type Change struct {
+	Kind     ChangeKind
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+// DuplicateKeyPolicy determines how UnmarshalJSONWithPolicy resolves a key occurring more than once
+// in a JSON object
+type DuplicateKeyPolicy int
+
+const (
+	// OverwriteDuplicates keeps the position of the first occurrence and the value of the last, like
+	// Set does; this is how UnmarshalJSON behaves
+	OverwriteDuplicates DuplicateKeyPolicy = iota
+	// KeepFirst keeps the position and value of the first occurrence, ignoring later ones
+	KeepFirst
+	// KeepLast keeps the position and value of the last occurrence, like encoding/json resolves the value
+	KeepLast
+	// RejectDuplicates returns an error naming the key, as UnmarshalJSONStrict does
+	RejectDuplicates
+)
+
+// DecodeLimits restricts the input accepted by DecodeWithLimits
+// A limit of zero means no limit. Lengths are counted in bytes. Only MaxBytes bounds the memory used
+// while reading, as MaxKeyLen and MaxValueLen are checked once a key or value has been decoded
+type DecodeLimits struct {
+	MaxBytes    int64 // maximum length of the input, enforced while reading
+	MaxEntries  int   // maximum number of members of the object, including duplicate keys
+	MaxKeyLen   int   // maximum length of a decoded key
+	MaxValueLen int   // maximum length of a decoded value
+}
+
+// Move relocates the entry at position From to position To, as returned by ReorderSteps
+type Move struct {
+	From int
+	To   int
+}
+
 // StringMap represents a map of string key/value pairs which maintains its order when marshaled to/from JSON
-// Like the built-in map, this type is not concurrency safe
+// Like the built-in map, this type is not concurrency safe; see SyncStringMap
 type StringMap struct {
 	keys   []string
 	values map[string]string
+	sealed bool
+}
+
+// NewStringMap returns an empty map with storage preallocated for capacity entries
+// The zero value of StringMap is an empty map as well; this only avoids growing it while adding entries
+func NewStringMap(capacity int) *StringMap {
+	return &StringMap{
+		keys:   make([]string, 0, capacity),
+		values: make(map[string]string, capacity),
+	}
+}
+
+// FromMap returns a map holding the entries of src in the order of keyOrder
+// keyOrder must list every key of src exactly once and nothing else, otherwise an error is returned
+func FromMap(src map[string]string, keyOrder []string) (StringMap, error) {
+	m := StringMap{
+		keys:   make([]string, 0, len(keyOrder)),
+		values: make(map[string]string, len(keyOrder)),
+	}
+	for _, key := range keyOrder {
+		value, exists := src[key]
+		if !exists {
+			return StringMap{}, fmt.Errorf("key %q not in map", key)
+		}
+		if _, dup := m.values[key]; dup {
+			return StringMap{}, fmt.Errorf("duplicate key %q in order", key)
+		}
+		m.keys = append(m.keys, key)
+		m.values[key] = value
+	}
+
+	if len(m.keys) < len(src) {
+		var missing []string
+		for key := range src {
+			if _, exists := m.values[key]; !exists {
+				missing = append(missing, key)
+			}
+		}
+		sort.Strings(missing)
+		return StringMap{}, fmt.Errorf("keys %q missing from order", missing)
+	}
+
+	return m, nil
+}
+
+// FromSortedMap returns a map holding the entries of src with the keys in sorted order
+func FromSortedMap(src map[string]string) StringMap {
+	m := StringMap{
+		keys:   make([]string, 0, len(src)),
+		values: make(map[string]string, len(src)),
+	}
+	for key, value := range src {
+		m.keys = append(m.keys, key)
+		m.values[key] = value
+	}
+	sort.Strings(m.keys)
+
+	return m
+}
+
+// FromPairs returns a map holding pairs in order
+// Like Set, a duplicate key overwrites the earlier value while keeping its earlier position
+func FromPairs(pairs ...Pair) StringMap {
+	var m StringMap
+	m.SetMany(pairs...)
+
+	return m
+}
+
+// Set sets a key to a value
+// If a key already exists it is overwritten
+func (m *StringMap) Set(key, value string) {
+	m.mutate()
+
+	if m.values == nil {
+		m.keys = append(m.keys, key)
+		m.values = map[string]string{key: value}
+	} else {
+		if _, exists := m.values[key]; !exists {
+			m.keys = append(m.keys, key)
+		}
+		m.values[key] = value
+	}
+}
+
+// SetMany sets every pair in order, like Set
+// Storage is grown up front to fit all pairs using Grow, so repeated calls take amortized constant
+// time per pair
+func (m *StringMap) SetMany(pairs ...Pair) {
+	m.Grow(len(pairs))
+
+	for _, pair := range pairs {
+		if _, exists := m.values[pair.Key]; !exists {
+			m.keys = append(m.keys, pair.Key)
+		}
+		m.values[pair.Key] = pair.Value
+	}
+}
+
+// Grow preallocates storage for at least n more entries, so adding them does not reallocate
+// Like append, the key storage grows geometrically, so repeated calls take amortized constant time.
+// As the capacity of a built-in map can not be inspected, the values map is only rebuilt with a larger
+// size hint when n exceeds what doubling the key storage provides. Nothing is done when n is not positive
+func (m *StringMap) Grow(n int) {
+	m.mutate()
+
+	need := len(m.keys) + n
+	if n <= 0 || (m.values != nil && cap(m.keys) >= need) {
+		return
+	}
+
+	capacity := 2 * cap(m.keys)
+	if m.values == nil || capacity < need {
+		if capacity < need {
+			capacity = need
+		}
+		values := make(map[string]string, capacity)
+		for key, value := range m.values {
+			values[key] = value
+		}
+		m.values = values
+	}
+	if cap(m.keys) < need {
+		keys := make([]string, len(m.keys), capacity)
+		copy(keys, m.keys)
+		m.keys = keys
+	}
+}
+
+// Compact releases storage left unused after removing entries, leaving the entries unchanged
+// Both the keys and the values map are reallocated to fit the current number of entries, as a
+// built-in map never shrinks by itself
+func (m *StringMap) Compact() {
+	m.mutate()
+
+	if m.values == nil {
+		return
+	}
+
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+	values := make(map[string]string, len(m.keys))
+	for key, value := range m.values {
+		values[key] = value
+	}
+	m.keys, m.values = keys, values
+}
+
+// Update sets key to the value computed by fn from its current value
+// fn receives the current value and whether the key exists; a new key is appended
+func (m *StringMap) Update(key string, fn func(old string, existed bool) string) {
+	old, existed := m.values[key]
+	m.Set(key, fn(old, existed))
+}
+
+// UpdateExisting sets an existing key to the value computed by fn from its current value
+// It returns false without calling fn if the key does not exist. The position of the key is unchanged
+func (m *StringMap) UpdateExisting(key string, fn func(old string) string) bool {
+	m.mutate()
+
+	old, exists := m.values[key]
+	if !exists {
+		return false
+	}
+
+	m.values[key] = fn(old)
+	return true
+}
+
+// GetOrSet returns the value for key and true if the key exists
+// Otherwise it appends key with value and returns value and false
+func (m *StringMap) GetOrSet(key, value string) (string, bool) {
+	if existing, exists := m.values[key]; exists {
+		return existing, true
+	}
+
+	m.insert(len(m.keys), key, value)
+	return value, false
+}
+
+// Seal makes the map immutable
+// Any method modifying a sealed map panics, while all methods reading it keep working; a sealed
+// map can therefore be read concurrently. A map can not be unsealed, but Clone returns a
+// mutable copy
+func (m *StringMap) Seal() {
+	m.sealed = true
+}
+
+// Sealed reports whether the map has been sealed
+func (m StringMap) Sealed() bool {
+	return m.sealed
+}
+
+// mutate panics if the map is sealed
+// It must be called by every method modifying the map before doing so
+func (m StringMap) mutate() {
+	if m.sealed {
+		panic("orderedmap: modification of sealed StringMap")
+	}
+}
+
+// Delete removes a key from the map, preserving the order of the remaining keys
+// Locating the key in the order takes linear time. Deleting a key which does not exist is a no-op
+func (m *StringMap) Delete(key string) {
+	m.mutate()
+
+	i := m.IndexOf(key)
+	if i < 0 {
+		return
+	}
+
+	delete(m.values, key)
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+}
+
+// Pop removes a key from the map and returns its value
+// ok is false if the key does not exist, in which case the map is unchanged
+func (m *StringMap) Pop(key string) (value string, ok bool) {
+	if value, ok = m.values[key]; ok {
+		m.Delete(key)
+	}
+
+	return value, ok
+}
+
+// PopFirst removes the first entry and returns it
+// ok is false for an empty map. The remaining keys are shifted, which takes linear time
+func (m *StringMap) PopFirst() (key, value string, ok bool) {
+	m.mutate()
+
+	if len(m.keys) == 0 {
+		return "", "", false
+	}
+
+	key = m.keys[0]
+	value = m.values[key]
+	delete(m.values, key)
+	m.keys = m.keys[:copy(m.keys, m.keys[1:])]
+
+	return key, value, true
+}
+
+// PopLast removes the last entry and returns it
+// ok is false for an empty map
+func (m *StringMap) PopLast() (key, value string, ok bool) {
+	m.mutate()
+
+	if len(m.keys) == 0 {
+		return "", "", false
+	}
+
+	key = m.keys[len(m.keys)-1]
+	value = m.values[key]
+	delete(m.values, key)
+	m.keys = m.keys[:len(m.keys)-1]
+
+	return key, value, true
+}
+
+// Clear removes all entries, retaining the allocated storage for reuse
+func (m *StringMap) Clear() {
+	m.mutate()
+
+	m.keys = m.keys[:0]
+	for key := range m.values {
+		delete(m.values, key)
+	}
+}
+
+// Clone returns a copy of the map which shares no storage with m
+// The copy is never sealed
+func (m StringMap) Clone() StringMap {
+	clone := StringMap{
+		keys:   make([]string, len(m.keys)),
+		values: make(map[string]string, len(m.keys)),
+	}
+	copy(clone.keys, m.keys)
+	for key, value := range m.values {
+		clone.values[key] = value
+	}
+
+	return clone
+}
+
+// First returns the first entry
+// ok is false for an empty map
+func (m StringMap) First() (key, value string, ok bool) {
+	if len(m.keys) == 0 {
+		return "", "", false
+	}
+
+	key = m.keys[0]
+	return key, m.values[key], true
+}
+
+// Last returns the last entry
+// ok is false for an empty map
+func (m StringMap) Last() (key, value string, ok bool) {
+	if len(m.keys) == 0 {
+		return "", "", false
+	}
+
+	key = m.keys[len(m.keys)-1]
+	return key, m.values[key], true
+}
+
+// IndexOf returns the position of key in the order, or -1 if the key does not exist
+// It scans the keys, taking linear time
+func (m StringMap) IndexOf(key string) int {
+	if _, exists := m.values[key]; !exists {
+		return -1
+	}
+
+	for i, k := range m.keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// KeyAt returns the key at position i
+// A negative i counts back from the end, so -1 is the last key. ok is false if i is out of range
+func (m StringMap) KeyAt(i int) (string, bool) {
+	if i < 0 {
+		i += len(m.keys)
+	}
+	if i < 0 || i >= len(m.keys) {
+		return "", false
+	}
+
+	return m.keys[i], true
+}
+
+// ValueAt returns the value at position i
+// A negative i counts back from the end, so -1 is the last value. ok is false if i is out of range
+func (m StringMap) ValueAt(i int) (string, bool) {
+	key, ok := m.KeyAt(i)
+	if !ok {
+		return "", false
+	}
+
+	return m.values[key], true
+}
+
+// Equal reports whether both maps hold the same entries in the same order
+func (m StringMap) Equal(other StringMap) bool {
+	if len(m.keys) != len(other.keys) {
+		return false
+	}
+
+	for i, key := range m.keys {
+		if other.keys[i] != key || other.values[key] != m.values[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualUnordered reports whether both maps hold the same entries, regardless of order
+func (m StringMap) EqualUnordered(other StringMap) bool {
+	if len(m.keys) != len(other.keys) {
+		return false
+	}
+
+	for key, value := range m.values {
+		if otherValue, exists := other.values[key]; !exists || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Keys returns the keys in order
+func (m StringMap) Keys() []string {
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+
+	return keys
+}
+
+// KeysWithPrefix returns the keys starting with prefix, in order
+// It returns an empty slice when no key matches
+func (m StringMap) KeysWithPrefix(prefix string) []string {
+	return m.KeysFunc(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// KeysFunc returns the keys for which pred returns true, in order
+// It returns an empty slice when no key matches
+func (m StringMap) KeysFunc(pred func(key string) bool) []string {
+	keys := []string{}
+	for _, key := range m.keys {
+		if pred(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Values returns the values in order
+func (m StringMap) Values() []string {
+	values := make([]string, len(m.keys))
+	for i, key := range m.keys {
+		values[i] = m.values[key]
+	}
+
+	return values
+}
+
+// Pairs returns the key/value pairs in order
+func (m StringMap) Pairs() []Pair {
+	pairs := make([]Pair, len(m.keys))
+	for i, key := range m.keys {
+		pairs[i] = Pair{key, m.values[key]}
+	}
+
+	return pairs
+}
+
+// Value returns the value for key
+func (m StringMap) Value(key string) (string, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Has reports whether key is present
+func (m StringMap) Has(key string) bool {
+	_, exists := m.values[key]
+	return exists
+}
+
+// ContainsValue reports whether any key holds value
+func (m StringMap) ContainsValue(value string) bool {
+	for _, key := range m.keys {
+		if m.values[key] == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValueInt returns the value for key parsed as an int
+// err is only set when the key exists but its value is not a valid int
+func (m StringMap) ValueInt(key string) (value int, ok bool, err error) {
+	s, ok := m.values[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	value, err = strconv.Atoi(s)
+	return value, true, err
+}
+
+// ValueBool returns the value for key parsed as a bool by strconv.ParseBool
+// err is only set when the key exists but its value is not a valid bool
+func (m StringMap) ValueBool(key string) (value bool, ok bool, err error) {
+	s, ok := m.values[key]
+	if !ok {
+		return false, false, nil
+	}
+
+	value, err = strconv.ParseBool(s)
+	return value, true, err
+}
+
+// ValueFloat returns the value for key parsed as a float64
+// err is only set when the key exists but its value is not a valid float
+func (m StringMap) ValueFloat(key string) (value float64, ok bool, err error) {
+	s, ok := m.values[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	value, err = strconv.ParseFloat(s, 64)
+	return value, true, err
+}
+
+// Sort sorts the list by value using the provided function
+func (m *StringMap) Sort(less func(s, t string) bool) {
+	m.mutate()
+
+	sort.Slice(m.keys, func(i, j int) bool {
+		// Use the value for sorting
+		return less(m.values[m.keys[i]], m.values[m.keys[j]])
+	})
+}
+
+// SortKeys sorts the list by key using the provided function
+func (m *StringMap) SortKeys(less func(s, t string) bool) {
+	m.mutate()
+
+	sort.Slice(m.keys, func(i, j int) bool {
+		return less(m.keys[i], m.keys[j])
+	})
+}
+
+// SortStable sorts the list by value using the provided function, keeping equal values in their
+// original order
+func (m *StringMap) SortStable(less func(s, t string) bool) {
+	m.mutate()
+
+	sort.SliceStable(m.keys, func(i, j int) bool {
+		return less(m.values[m.keys[i]], m.values[m.keys[j]])
+	})
+}
+
+// SortKeysStable sorts the list by key using the provided function, keeping equal keys in their
+// original order
+func (m *StringMap) SortKeysStable(less func(s, t string) bool) {
+	m.mutate()
+
+	sort.SliceStable(m.keys, func(i, j int) bool {
+		return less(m.keys[i], m.keys[j])
+	})
+}
+
+// Reverse reverses the order of the keys, regardless of the values
+func (m *StringMap) Reverse() {
+	m.mutate()
+
+	for i, j := 0, len(m.keys)-1; i < j; i, j = i+1, j-1 {
+		m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+	}
+}
+
+// MapValues returns a new map with the same keys in the same order, holding the result of
+// transform for each entry
+func (m StringMap) MapValues(transform func(key, value string) string) StringMap {
+	mapped := m.Clone()
+	mapped.Apply(transform)
+
+	return mapped
+}
+
+// Apply replaces every value by the result of transform for its entry
+func (m *StringMap) Apply(transform func(key, value string) string) {
+	m.mutate()
+
+	for _, key := range m.keys {
+		m.values[key] = transform(key, m.values[key])
+	}
+}
+
+// Rename replaces oldKey by newKey, keeping its value and position
+// It returns an error if oldKey does not exist or newKey already does; renaming a key to itself is a no-op
+func (m *StringMap) Rename(oldKey, newKey string) error {
+	m.mutate()
+
+	i := m.IndexOf(oldKey)
+	if i < 0 {
+		return fmt.Errorf("key %q is missing", oldKey)
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	if _, exists := m.values[newKey]; exists {
+		return fmt.Errorf("key %q already exists", newKey)
+	}
+
+	m.keys[i] = newKey
+	m.values[newKey] = m.values[oldKey]
+	delete(m.values, oldKey)
+	return nil
+}
+
+// MapKeys returns a new map with every key replaced by the result of transform
+// Values and order are retained; an error is returned when two keys transform into the same key
+func (m StringMap) MapKeys(transform func(key string) string) (StringMap, error) {
+	var mapped StringMap
+	origins := make(map[string]string, len(m.keys))
+	for _, key := range m.keys {
+		newKey := transform(key)
+		if origin, exists := origins[newKey]; exists {
+			return StringMap{}, fmt.Errorf("keys %q and %q both map to %q", origin, key, newKey)
+		}
+		origins[newKey] = key
+		mapped.Set(newKey, m.values[key])
+	}
+
+	return mapped, nil
+}
+
+// RewriteKeys returns a new map with every key replaced by re.ReplaceAllString(key, repl)
+// repl may refer to submatches like $1. Values and order are retained; an error is returned when
+// two keys are rewritten into the same key
+func (m StringMap) RewriteKeys(re *regexp.Regexp, repl string) (StringMap, error) {
+	return m.MapKeys(func(key string) string {
+		return re.ReplaceAllString(key, repl)
+	})
+}
+
+// PrefixKeys prepends prefix to every key
+// Order and values are retained
+func (m *StringMap) PrefixKeys(prefix string) {
+	m.affixKeys(func(key string) string { return prefix + key })
+}
+
+// SuffixKeys appends suffix to every key
+// Order and values are retained
+func (m *StringMap) SuffixKeys(suffix string) {
+	m.affixKeys(func(key string) string { return key + suffix })
+}
+
+// affixKeys replaces every key in place by the result of affix
+// affix must not map distinct keys to the same key
+func (m *StringMap) affixKeys(affix func(key string) string) {
+	m.mutate()
+
+	if len(m.keys) == 0 {
+		return
+	}
+
+	values := make(map[string]string, len(m.keys))
+	for i, key := range m.keys {
+		m.keys[i] = affix(key)
+		values[m.keys[i]] = m.values[key]
+	}
+	m.values = values
+}
+
+// Head returns a new map holding the first n entries
+// All entries are returned when n exceeds the length of the map, none when n is not positive
+func (m StringMap) Head(n int) StringMap {
+	if n > len(m.keys) {
+		n = len(m.keys)
+	} else if n < 0 {
+		n = 0
+	}
+
+	return m.subset(m.keys[:n])
+}
+
+// Tail returns a new map holding the last n entries, the most recently inserted ones
+// All entries are returned when n exceeds the length of the map, none when n is not positive
+func (m StringMap) Tail(n int) StringMap {
+	if n > len(m.keys) {
+		n = len(m.keys)
+	} else if n < 0 {
+		n = 0
+	}
+
+	return m.subset(m.keys[len(m.keys)-n:])
+}
+
+// subset returns a new map holding the given keys of m in the given order
+func (m StringMap) subset(keys []string) StringMap {
+	var sub StringMap
+	for _, key := range keys {
+		sub.Set(key, m.values[key])
+	}
+
+	return sub
+}
+
+// CoalesceGroup returns the first non-empty value of the group of keys below prefix
+// The group consists of the key prefix itself and all keys starting with prefix followed by sep,
+// scanned in order. ok is false if the group has no keys at all
+func (m StringMap) CoalesceGroup(prefix, sep string) (value string, ok bool) {
+	for _, key := range m.keys {
+		if key != prefix && !strings.HasPrefix(key, prefix+sep) {
+			continue
+		}
+
+		ok = true
+		if value = m.values[key]; value != "" {
+			return value, true
+		}
+	}
+
+	return "", ok
+}
+
+// ValidateKeys checks the keys of the map against an allow-list
+// It returns a *KeysError listing all required keys that are missing and all keys that are
+// neither required nor optional
+func (m StringMap) ValidateKeys(required, optional []string) error {
+	var err KeysError
+	allowed := make(map[string]bool, len(required)+len(optional))
+	for _, key := range required {
+		if _, exists := m.values[key]; !exists {
+			err.Missing = append(err.Missing, key)
+		}
+		allowed[key] = true
+	}
+	for _, key := range optional {
+		allowed[key] = true
+	}
+
+	for _, key := range m.keys {
+		if !allowed[key] {
+			err.Unexpected = append(err.Unexpected, key)
+		}
+	}
+
+	if len(err.Missing) > 0 || len(err.Unexpected) > 0 {
+		return &err
+	}
+	return nil
+}
+
+// Stream returns a channel which yields all entries in order and is then closed
+// The entries are buffered in the channel up front, so no goroutine is left behind when the
+// receiver stops reading early and the map may be modified once Stream returns
+func (m StringMap) Stream() <-chan Entry {
+	ch := make(chan Entry, len(m.keys))
+	for _, key := range m.keys {
+		ch <- Entry{key, m.values[key]}
+	}
+	close(ch)
+
+	return ch
+}
+
+// StreamContext returns a channel over which entries are sent in order until all are sent or
+// ctx is done, after which the channel is closed
+// Entries are read from the map while streaming, so it must not be modified until the channel is closed.
+// The sending goroutine only terminates once the channel is closed, so a receiver which stops reading
+// early must cancel ctx; otherwise it must drain the channel
+func (m StringMap) StreamContext(ctx context.Context) <-chan Entry {
+	ch := make(chan Entry)
+	go func() {
+		defer close(ch)
+		for _, key := range m.keys {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case ch <- Entry{key, m.values[key]}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// DuplicateKeyPositions reports keys which occur more than once in the key order along with
+// their positions
+// A map that is only manipulated through its methods never has duplicate keys; this serves as a
+// diagnostic to catch violations of that invariant. It returns nil if all keys are unique
+func (m StringMap) DuplicateKeyPositions() map[string][]int {
+	var duplicates map[string][]int
+	positions := make(map[string]int, len(m.keys))
+	for i, key := range m.keys {
+		first, seen := positions[key]
+		if !seen {
+			positions[key] = i
+			continue
+		}
+
+		if duplicates == nil {
+			duplicates = make(map[string][]int)
+		}
+		if _, found := duplicates[key]; !found {
+			duplicates[key] = []int{first}
+		}
+		duplicates[key] = append(duplicates[key], i)
+	}
+
+	return duplicates
+}
+
+// ReplaceAll replaces the contents of the map by the given entries, in order
+// Existing allocations are reused where possible; duplicate keys are handled like Set does
+func (m *StringMap) ReplaceAll(entries []Entry) {
+	m.Clear()
+	for _, entry := range entries {
+		m.Set(entry.Key, entry.Value)
+	}
+}
+
+// CommonKeys returns the keys present in every one of maps, in order of the first map
+// Without any maps it returns an empty slice
+func CommonKeys(maps ...StringMap) []string {
+	common := []string{}
+	if len(maps) == 0 {
+		return common
+	}
+
+	counts := make(map[string]int, len(maps[0].keys))
+	for _, m := range maps {
+		for _, key := range m.keys {
+			counts[key]++
+		}
+	}
+
+	for _, key := range maps[0].keys {
+		if counts[key] == len(maps) {
+			common = append(common, key)
+		}
+	}
+
+	return common
+}
+
+// Render expands the text/template tmpl for each entry in order and returns the concatenated output
+// The template is executed with an Entry, so it can refer to {{.Key}} and {{.Value}}
+func (m StringMap) Render(tmpl string) (string, error) {
+	t, err := template.New("entry").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, key := range m.keys {
+		if err := t.Execute(&buf, Entry{key, m.values[key]}); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// UniqueValues removes all entries holding a value which already occurred earlier in the map
+// The first key holding each distinct value is kept. It returns the number of entries removed
+func (m *StringMap) UniqueValues() int {
+	seen := make(map[string]bool, len(m.keys))
+	return m.retain(func(_, value string) bool {
+		if seen[value] {
+			return false
+		}
+		seen[value] = true
+		return true
+	})
+}
+
+// EncodeSharded distributes the entries round-robin over writers and writes a JSON object
+// holding its share of the entries, in order, to each writer
+func (m StringMap) EncodeSharded(writers []io.Writer) error {
+	if len(writers) == 0 {
+		return errors.New("no writers to encode to")
+	}
+
+	shards := make([]StringMap, len(writers))
+	for i, key := range m.keys {
+		shards[i%len(shards)].Set(key, m.values[key])
+	}
+
+	for i, shard := range shards {
+		b, err := shard.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if _, err = writers[i].Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Changelog lists the changes which turn m into other
+// Removals and modifications come first, in order of m, followed by additions in order of other
+func (m StringMap) Changelog(other StringMap) []Change {
+	var changes []Change
+	for _, key := range m.keys {
+		oldValue := m.values[key]
+		if newValue, exists := other.values[key]; !exists {
+			changes = append(changes, Change{Kind: Removed, Key: key, OldValue: oldValue})
+		} else if newValue != oldValue {
+			changes = append(changes, Change{Kind: Modified, Key: key, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for _, key := range other.keys {
+		if _, exists := m.values[key]; !exists {
+			changes = append(changes, Change{Kind: Added, Key: key, NewValue: other.values[key]})
+		}
+	}
+
+	return changes
+}
+
+// SetSorted sets a key to a value, inserting a new key at the position dictated by less
+// The map is assumed to be sorted by less already, so the position is found by binary search. New
+// keys are placed after existing entries comparing equal. An existing key is updated in place
+func (m *StringMap) SetSorted(key, value string, less func(aKey, aVal, bKey, bVal string) bool) {
+	m.mutate()
+
+	if _, exists := m.values[key]; exists {
+		m.values[key] = value
+		return
+	}
+
+	i := sort.Search(len(m.keys), func(i int) bool {
+		return less(key, value, m.keys[i], m.values[m.keys[i]])
+	})
+	m.insert(i, key, value)
+}
+
+// InsertAt sets a key to a value and places the key at position i
+// An existing key is moved from its old position. i must be within the range of positions after
+// the insertion; inserting a new key at Len() is the same as setting it
+func (m *StringMap) InsertAt(i int, key, value string) error {
+	m.mutate()
+
+	current := m.IndexOf(key)
+	last := len(m.keys)
+	if current >= 0 {
+		last--
+	}
+	if i < 0 || i > last {
+		return fmt.Errorf("index %d out of range [0, %d]", i, last)
+	}
+
+	if current < 0 {
+		m.insert(i, key, value)
+	} else {
+		m.values[key] = value
+		m.move(current, i)
+	}
+	return nil
+}
+
+// MoveToFront moves an existing key to the first position, leaving its value unchanged
+// It returns false if the key does not exist
+func (m *StringMap) MoveToFront(key string) bool {
+	i := m.IndexOf(key)
+	if i < 0 {
+		return false
+	}
+
+	m.move(i, 0)
+	return true
+}
+
+// MoveToBack moves an existing key to the last position, leaving its value unchanged
+// It returns false if the key does not exist
+func (m *StringMap) MoveToBack(key string) bool {
+	i := m.IndexOf(key)
+	if i < 0 {
+		return false
+	}
+
+	m.move(i, len(m.keys)-1)
+	return true
+}
+
+// MoveBefore moves an existing key to the position directly before mark
+// It returns false if either key does not exist. Moving a key before itself changes nothing
+func (m *StringMap) MoveBefore(key, mark string) bool {
+	from, at := m.IndexOf(key), m.IndexOf(mark)
+	if from < 0 || at < 0 {
+		return false
+	}
+
+	if from < at {
+		at--
+	}
+	m.move(from, at)
+	return true
+}
+
+// MoveAfter moves an existing key to the position directly after mark
+// It returns false if either key does not exist. Moving a key after itself changes nothing
+func (m *StringMap) MoveAfter(key, mark string) bool {
+	from, at := m.IndexOf(key), m.IndexOf(mark)
+	if from < 0 || at < 0 {
+		return false
+	}
+
+	if from > at {
+		at++
+	}
+	m.move(from, at)
+	return true
+}
+
+// insert adds a new key with value at position i
+func (m *StringMap) insert(i int, key, value string) {
+	m.mutate()
+
+	if m.values == nil {
+		m.values = make(map[string]string)
+	}
+	m.values[key] = value
+
+	m.keys = append(m.keys, "")
+	copy(m.keys[i+1:], m.keys[i:])
+	m.keys[i] = key
+}
+
+// move relocates the key at position from to position to, shifting the keys in between
+func (m *StringMap) move(from, to int) {
+	m.mutate()
+
+	key := m.keys[from]
+	if from < to {
+		copy(m.keys[from:to], m.keys[from+1:to+1])
+	} else {
+		copy(m.keys[to+1:from+1], m.keys[to:from])
+	}
+	m.keys[to] = key
+}
+
+// ValuesWithPrefix returns the values of all keys starting with prefix, in order
+func (m StringMap) ValuesWithPrefix(prefix string) []string {
+	values := []string{}
+	for _, key := range m.keys {
+		if strings.HasPrefix(key, prefix) {
+			values = append(values, m.values[key])
+		}
+	}
+
+	return values
+}
+
+// Merge sets all entries of other, in order of other
+// Like Set, existing keys keep their position while taking the value from other, and new keys
+// are appended
+func (m *StringMap) Merge(other StringMap) {
+	for _, key := range other.keys {
+		m.Set(key, other.values[key])
+	}
+}
+
+// ApplyDefaults appends the entries of defaults whose key is not yet present, in order of defaults
+// Keys that are already present keep their value and position
+func (m *StringMap) ApplyDefaults(defaults StringMap) {
+	for _, key := range defaults.keys {
+		if _, exists := m.values[key]; !exists {
+			m.Set(key, defaults.values[key])
+		}
+	}
+}
+
+// Intersect returns a new map holding the entries of m whose key is present in other, in order of m
+// Values are taken from m
+func (m StringMap) Intersect(other StringMap) StringMap {
+	var keys []string
+	for _, key := range m.keys {
+		if _, exists := other.values[key]; exists {
+			keys = append(keys, key)
+		}
+	}
+
+	return m.subset(keys)
+}
+
+// Union returns a new map holding the entries of m followed by those of other whose key is not in m
+// Keys present in both maps keep the position from m and take the value from other, like Merge does
+func (m StringMap) Union(other StringMap) StringMap {
+	union := m.Clone()
+	union.Merge(other)
+
+	return union
+}
+
+// Difference returns a new map holding the entries of m whose key is not present in other, in order of m
+func (m StringMap) Difference(other StringMap) StringMap {
+	var keys []string
+	for _, key := range m.keys {
+		if _, exists := other.values[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+
+	return m.subset(keys)
 }
 
-// Set sets a key to a value
-// If a key already exists it is overwritten
-func (m *StringMap) Set(key, value string) {
-	if m.values == nil {
-		m.keys = append(m.keys, key)
-		m.values = map[string]string{key: value}
-	} else {
-		if _, exists := m.values[key]; !exists {
-			m.keys = append(m.keys, key)
+// UnifiedDiff renders the differences between m and other as lines of text
+// Every entry is written as key=value on its own line, prefixed by a space when unchanged, a '-'
+// when only in m or changed from, and a '+' when only in other or changed to. The lines follow a
+// longest common subsequence of both key orders, so moved keys show up as a removal at their old
+// position and an addition at their new position
+func (m StringMap) UnifiedDiff(other StringMap) string {
+	a, b := m.keys, other.keys
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
 		}
-		m.values[key] = value
 	}
+
+	var buf strings.Builder
+	line := func(prefix byte, key, value string) {
+		buf.WriteByte(prefix)
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			if oldValue, newValue := m.values[a[i]], other.values[b[j]]; oldValue == newValue {
+				line(' ', a[i], oldValue)
+			} else {
+				line('-', a[i], oldValue)
+				line('+', b[j], newValue)
+			}
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			line('-', a[i], m.values[a[i]])
+			i++
+		default:
+			line('+', b[j], other.values[b[j]])
+			j++
+		}
+	}
+
+	return buf.String()
 }
 
-// Keys returns the keys in order
-func (m StringMap) Keys() []string {
-	keys := make([]string, len(m.keys))
-	copy(keys, m.keys)
+// ForEach calls fn for every entry in order, until fn returns false
+// Like ranging over a built-in map, the result of modifying the map from fn is undefined
+func (m StringMap) ForEach(fn func(key, value string) bool) {
+	for _, key := range m.keys {
+		if !fn(key, m.values[key]) {
+			return
+		}
+	}
+}
 
-	return keys
+// EachChunk calls fn with successive chunks of up to size entries in order, until fn returns false
+// All chunks share a single backing array, so fn must not retain the slice after it returns.
+// Nothing is done when size is not positive
+func (m StringMap) EachChunk(size int, fn func(chunk []Entry) bool) {
+	if size <= 0 {
+		return
+	}
+	if size > len(m.keys) {
+		size = len(m.keys)
+	}
+
+	chunk := make([]Entry, 0, size)
+	for i, key := range m.keys {
+		chunk = append(chunk, Entry{key, m.values[key]})
+		if len(chunk) == size || i == len(m.keys)-1 {
+			if !fn(chunk) {
+				return
+			}
+			chunk = chunk[:0]
+		}
+	}
 }
 
-// Value returns the value for key
-func (m StringMap) Value(key string) (string, bool) {
-	value, ok := m.values[key]
-	return value, ok
+// ExtremeByKey returns the keys which would sort first and last by less, without sorting
+// On ties the key first in order wins. ok is false for an empty map
+func (m StringMap) ExtremeByKey(less func(a, b string) bool) (minKey, maxKey string, ok bool) {
+	return m.extremes(less)
 }
 
-// Sort sorts the list by value using the provided function
-func (m *StringMap) Sort(less func(s, t string) bool) {
-	sort.Slice(m.keys, func(i, j int) bool {
-		// Use the value for sorting
-		return less(m.values[m.keys[i]], m.values[m.keys[j]])
-	})
+// ExtremeByValue returns the keys of the values which would sort first and last by less, without sorting
+// On ties the key first in order wins. ok is false for an empty map
+func (m StringMap) ExtremeByValue(less func(a, b string) bool) (minKey, maxKey string, ok bool) {
+	return m.extremes(func(a, b string) bool { return less(m.values[a], m.values[b]) })
 }
 
-// SortKeys sorts the list by key using the provided function
-func (m *StringMap) SortKeys(less func(s, t string) bool) {
-	sort.Slice(m.keys, func(i, j int) bool {
-		return less(m.keys[i], m.keys[j])
+// extremes finds the first and last key by comparing keys with less in a single pass
+func (m StringMap) extremes(less func(a, b string) bool) (minKey, maxKey string, ok bool) {
+	if len(m.keys) == 0 {
+		return "", "", false
+	}
+
+	minKey, maxKey = m.keys[0], m.keys[0]
+	for _, key := range m.keys[1:] {
+		if less(key, minKey) {
+			minKey = key
+		}
+		if less(maxKey, key) {
+			maxKey = key
+		}
+	}
+
+	return minKey, maxKey, true
+}
+
+// ProjectValues returns a new map with the same keys in the same order, holding the result of fn
+// for each value
+func (m StringMap) ProjectValues(fn func(value string) string) StringMap {
+	return m.MapValues(func(_, value string) string {
+		return fn(value)
 	})
 }
 
-// MarshalJSON implements json.Marshaler
-func (m StringMap) MarshalJSON() ([]byte, error) {
-	var buf bytes.Buffer
+// MergeSorted merges a and b, which must both be sorted by key using less, into a new sorted map
+// It runs in linear time. A key present in both maps is included once, with the value from b
+func MergeSorted(a, b StringMap, less func(aKey, bKey string) bool) StringMap {
+	var merged StringMap
+	merged.keys = make([]string, 0, len(a.keys)+len(b.keys))
+
+	i, j := 0, 0
+	for i < len(a.keys) && j < len(b.keys) {
+		aKey, bKey := a.keys[i], b.keys[j]
+		switch {
+		case aKey == bKey:
+			merged.Set(bKey, b.values[bKey])
+			i++
+			j++
+		case less(bKey, aKey):
+			merged.Set(bKey, b.values[bKey])
+			j++
+		default:
+			merged.Set(aKey, a.values[aKey])
+			i++
+		}
+	}
+	for ; i < len(a.keys); i++ {
+		merged.Set(a.keys[i], a.values[a.keys[i]])
+	}
+	for ; j < len(b.keys); j++ {
+		merged.Set(b.keys[j], b.values[b.keys[j]])
+	}
+
+	return merged
+}
+
+// RankMap returns a map of every key to its zero-based position in the order
+// The result is a snapshot; it does not reflect later modifications of the map
+func (m StringMap) RankMap() map[string]int {
+	ranks := make(map[string]int, len(m.keys))
+	for i, key := range m.keys {
+		ranks[key] = i
+	}
+
+	return ranks
+}
+
+// SetAt sets the value of the entry at position index
+// The key and the order remain unchanged; an error is returned if index is out of range
+func (m *StringMap) SetAt(index int, value string) error {
+	m.mutate()
+
+	if index < 0 || index >= len(m.keys) {
+		return fmt.Errorf("index %d out of range for %d entries", index, len(m.keys))
+	}
+
+	m.values[m.keys[index]] = value
+	return nil
+}
+
+// RoundTripStable reports whether marshaling the map to JSON and unmarshaling it again results
+// in the same keys, order and values
+func (m StringMap) RoundTripStable() (bool, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return false, err
+	}
+
+	var decoded StringMap
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		return false, err
+	}
+
+	return m.Equal(decoded), nil
+}
+
+// EntryHashes returns an FNV-1a hash of every entry in order
+// An entry is hashed as its key and value separated by a NUL byte; comparing the hashes of two
+// versions of a map position by position reveals which entries changed
+func (m StringMap) EntryHashes() []uint64 {
+	hashes := make([]uint64, len(m.keys))
+	h := fnv.New64a()
+	for i, key := range m.keys {
+		h.Reset()
+		_, _ = io.WriteString(h, key)
+		_, _ = h.Write([]byte{0})
+		_, _ = io.WriteString(h, m.values[key])
+		hashes[i] = h.Sum64()
+	}
+
+	return hashes
+}
+
+// NormalizeLineEndings replaces all line endings in the values, "\r\n", "\r" and "\n", by to
+// An empty to normalizes to "\n". Keys and order are retained
+func (m *StringMap) NormalizeLineEndings(to string) {
+	m.mutate()
+
+	if to == "" {
+		to = "\n"
+	}
+	replacer := strings.NewReplacer("\r\n", to, "\r", to, "\n", to)
+	for key, value := range m.values {
+		m.values[key] = replacer.Replace(value)
+	}
+}
+
+// BreakdownBy counts the entries per category as classified by classify
+// It returns a map of each category, in order of first appearance, to its count in decimal
+func (m StringMap) BreakdownBy(classify func(key, value string) string) StringMap {
+	var categories []string
+	counts := make(map[string]int)
+	for _, key := range m.keys {
+		category := classify(key, m.values[key])
+		if _, seen := counts[category]; !seen {
+			categories = append(categories, category)
+		}
+		counts[category]++
+	}
+
+	var breakdown StringMap
+	for _, category := range categories {
+		breakdown.Set(category, strconv.Itoa(counts[category]))
+	}
+
+	return breakdown
+}
+
+// EachRecover calls fn for each entry in order, recovering from any panic in fn
+// It returns the keys for which fn panicked, in order
+func (m StringMap) EachRecover(fn func(key, value string)) (failed []string) {
+	for _, key := range m.keys {
+		if !callRecover(fn, key, m.values[key]) {
+			failed = append(failed, key)
+		}
+	}
+
+	return failed
+}
+
+// callRecover calls fn and reports whether it returned without panicking
+func callRecover(fn func(key, value string), key, value string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	fn(key, value)
+	return true
+}
+
+// PresenceMask returns a bitmask in which bit i is set if keys[i] is present
+// Only the first 64 keys fit the mask; use Presence for longer lists of keys
+func (m StringMap) PresenceMask(keys []string) uint64 {
+	var mask uint64
+	for i, key := range keys {
+		if i == 64 {
+			break
+		}
+		if _, exists := m.values[key]; exists {
+			mask |= 1 << uint(i)
+		}
+	}
+
+	return mask
+}
+
+// Presence reports for every one of keys whether it is present
+func (m StringMap) Presence(keys []string) []bool {
+	present := make([]bool, len(keys))
+	for i, key := range keys {
+		_, present[i] = m.values[key]
+	}
+
+	return present
+}
+
+// NewKeysSince returns the keys which are not present in snapshot, in order
+func (m StringMap) NewKeysSince(snapshot StringMap) []string {
+	keys := []string{}
+	for _, key := range m.keys {
+		if _, exists := snapshot.values[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// WriteHTMLDefinitionList writes the entries in order as an HTML definition list to w
+// Keys and values are HTML escaped, so the output is safe for untrusted entries
+func (m StringMap) WriteHTMLDefinitionList(w io.Writer) error {
+	var buf strings.Builder
+	buf.WriteString("<dl>")
+	for _, key := range m.keys {
+		buf.WriteString("<dt>")
+		buf.WriteString(html.EscapeString(key))
+		buf.WriteString("</dt><dd>")
+		buf.WriteString(html.EscapeString(m.values[key]))
+		buf.WriteString("</dd>")
+	}
+	buf.WriteString("</dl>")
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// ReorderSteps returns the moves which turn the order of m into the order of other
+// The moves are to be applied one after another; each takes out the entry at From and inserts it
+// again at To. An error is returned when both maps do not have the same set of keys
+func (m StringMap) ReorderSteps(other StringMap) ([]Move, error) {
+	if len(m.keys) != len(other.keys) {
+		return nil, errors.New("maps have different keys")
+	}
+	for _, key := range other.keys {
+		if _, exists := m.values[key]; !exists {
+			return nil, fmt.Errorf("key %q is missing", key)
+		}
+	}
+
+	order := m.Keys()
+	var moves []Move
+	for to, key := range other.keys {
+		from := to
+		for order[from] != key {
+			from++
+		}
+		if from == to {
+			continue
+		}
+
+		copy(order[to+1:from+1], order[to:from])
+		order[to] = key
+		moves = append(moves, Move{From: from, To: to})
+	}
+
+	return moves, nil
+}
+
+// EachMutable calls fn for each entry in order, storing the value fn returns or removing the
+// entry when keep is false
+// fn iterates a snapshot of the keys, so it may safely modify the map itself; keys removed before
+// their turn are skipped. Surviving entries retain their relative order
+func (m *StringMap) EachMutable(fn func(key, value string) (newValue string, keep bool)) {
+	for _, key := range m.Keys() {
+		value, exists := m.values[key]
+		if !exists {
+			continue
+		}
+
+		if newValue, keep := fn(key, value); keep {
+			m.Set(key, newValue)
+		} else {
+			m.Delete(key)
+		}
+	}
+}
+
+// ValidateValues validates the value of each key for which validators has a validator
+// It returns a ValuesError holding all failures, or nil when all values are valid
+func (m StringMap) ValidateValues(validators map[string]func(value string) error) error {
+	var failures ValuesError
+	for _, key := range m.keys {
+		validate, ok := validators[key]
+		if !ok {
+			continue
+		}
+		if err := validate(m.values[key]); err != nil {
+			failures = append(failures, ValueError{Key: key, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+// IntersectKeysWith returns a new map holding the entries whose key appears in every one of
+// keySets, in order of m
+// Without any key sets all entries are returned; an empty key set results in an empty map
+func (m StringMap) IntersectKeysWith(keySets ...[]string) StringMap {
+	counts := make(map[string]int, len(m.keys))
+	for _, keySet := range keySets {
+		seen := make(map[string]bool, len(keySet))
+		for _, key := range keySet {
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	var keys []string
+	for _, key := range m.keys {
+		if counts[key] == len(keySets) {
+			keys = append(keys, key)
+		}
+	}
+
+	return m.subset(keys)
+}
+
+// BulkLoad replaces the contents of the map by adopting keys as order and values as storage
+// Every key must occur exactly once in keys and be present in values, without values holding any
+// other keys; otherwise an error is returned and the map is left unchanged. The caller must not
+// use keys or values after a successful call
+func (m *StringMap) BulkLoad(keys []string, values map[string]string) error {
+	m.mutate()
+
+	if len(keys) != len(values) {
+		return fmt.Errorf("got %d keys for %d values", len(keys), len(values))
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			return fmt.Errorf("duplicate key %q", key)
+		}
+		if _, exists := values[key]; !exists {
+			return fmt.Errorf("missing value for key %q", key)
+		}
+		seen[key] = true
+	}
+
+	m.keys = keys
+	m.values = values
+	return nil
+}
+
+// CacheKey returns a short string identifying the ordered contents of the map
+// It is the unpadded URL-safe base64 encoding of the SHA-256 hash of the wire encoding, which
+// distinguishes every combination of keys, values and order
+func (m StringMap) CacheKey() string {
+	sum := sha256.Sum256(m.AppendWire(nil))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// String implements fmt.Stringer, formatting the entries in order like StringMap{first:1, second:2}
+func (m StringMap) String() string {
+	var buf strings.Builder
+	buf.WriteString("StringMap{")
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(key)
+		buf.WriteByte(':')
+		buf.WriteString(m.values[key])
+	}
+	buf.WriteByte('}')
+
+	return buf.String()
+}
 
-	buf.WriteString("{")
+// GoString implements fmt.GoStringer, formatting the entries in order with quoted keys and values
+// like orderedmap.StringMap{"first":"1", "second":"2"}
+func (m StringMap) GoString() string {
+	var buf strings.Builder
+	buf.WriteString("orderedmap.StringMap{")
 	for i, key := range m.keys {
-		var bKey, bVal []byte
 		if i > 0 {
-			buf.WriteString(",")
+			buf.WriteString(", ")
 		}
+		buf.WriteString(strconv.Quote(key))
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Quote(m.values[key]))
+	}
+	buf.WriteByte('}')
+
+	return buf.String()
+}
+
+// retain removes all entries for which keep returns false, preserving the order of the others
+// It returns the number of entries removed
+func (m *StringMap) retain(keep func(key, value string) bool) int {
+	m.mutate()
+
+	kept := m.keys[:0]
+	for _, key := range m.keys {
+		if keep(key, m.values[key]) {
+			kept = append(kept, key)
+		} else {
+			delete(m.values, key)
+		}
+	}
+
+	removed := len(m.keys) - len(kept)
+	m.keys = kept
+	return removed
+}
+
+// MarshalJSON implements json.Marshaler
+func (m StringMap) MarshalJSON() ([]byte, error) {
+	return m.marshalJSON(true), nil
+}
+
+// MarshalJSONUnescaped is like MarshalJSON but leaves the characters <, > and & unescaped
+func (m StringMap) MarshalJSONUnescaped() ([]byte, error) {
+	return m.marshalJSON(false), nil
+}
 
-		// marshal key
-		bKey, _ = json.Marshal(key)
-		buf.Write(bKey)
-		buf.WriteString(":")
+// marshalJSON returns the JSON encoding of the map, escaping characters special to HTML if escapeHTML is true
+func (m StringMap) marshalJSON(escapeHTML bool) []byte {
+	buf := []byte{'{'}
+	for i := range m.keys {
+		buf = m.appendJSONEntry(buf, i, escapeHTML)
+	}
+	return append(buf, '}')
+}
 
-		// marshal value
-		bVal, _ = json.Marshal(m.values[key])
-		buf.Write(bVal)
+// MarshalIndent is like MarshalJSON but formats the output like json.MarshalIndent does
+func (m StringMap) MarshalIndent(prefix, indent string) ([]byte, error) {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
 	}
-	buf.WriteString("}")
 
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
 	return buf.Bytes(), nil
 }
 
+// Encode writes the same JSON encoding as MarshalJSON to w
+// Entries are written one at a time as they are encoded; the first write error is returned
+func (m StringMap) Encode(w io.Writer) error {
+	buf := []byte{'{'}
+	for i := range m.keys {
+		buf = m.appendJSONEntry(buf, i, true)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		buf = buf[:0]
+	}
+
+	_, err := w.Write(append(buf, '}'))
+	return err
+}
+
+// JSONReader returns a reader producing the same JSON encoding as MarshalJSON
+// Entries are encoded lazily while reading, so the map must not be modified until the reader is drained
+func (m StringMap) JSONReader() io.Reader {
+	return &jsonReader{m: m, next: -1}
+}
+
+// jsonReader encodes one entry at a time into its buffer
+type jsonReader struct {
+	m    StringMap
+	next int // the entry to encode next; -1 for the opening brace
+	buf  []byte
+}
+
+func (r *jsonReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		switch {
+		case r.next < 0:
+			r.buf = append(r.buf, '{')
+		case r.next < len(r.m.keys):
+			r.buf = r.m.appendJSONEntry(r.buf, r.next, true)
+		case r.next == len(r.m.keys):
+			r.buf = append(r.buf, '}')
+		default:
+			return 0, io.EOF
+		}
+		r.next++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// appendJSONEntry appends the JSON encoding of entry i as object member to dst
+// Characters special to HTML are escaped unless escapeHTML is false
+func (m StringMap) appendJSONEntry(dst []byte, i int, escapeHTML bool) []byte {
+	if i > 0 {
+		dst = append(dst, ',')
+	}
+
+	key := m.keys[i]
+	dst = appendJSONString(dst, key, escapeHTML)
+	dst = append(dst, ':')
+	return appendJSONString(dst, m.values[key], escapeHTML)
+}
+
+// appendJSONString appends the JSON encoding of s to dst
+func appendJSONString(dst []byte, s string, escapeHTML bool) []byte {
+	if escapeHTML {
+		b, _ := json.Marshal(s)
+		return append(dst, b...)
+	}
+
+	buf := bytes.NewBuffer(dst)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+
+	b := buf.Bytes()
+	return b[:len(b)-1] // Encode terminates with a newline
+}
+
 // UnmarshalJSON implements json.Unmarshaler
 func (m *StringMap) UnmarshalJSON(b []byte) error {
-	d := json.NewDecoder(bytes.NewReader(b))
+	return m.decode(json.NewDecoder(bytes.NewReader(b)), OverwriteDuplicates, DecodeLimits{})
+}
+
+// UnmarshalJSONStrict is like UnmarshalJSON but returns an error for a key occurring more than once
+// in the object
+func (m *StringMap) UnmarshalJSONStrict(b []byte) error {
+	return m.UnmarshalJSONWithPolicy(b, RejectDuplicates)
+}
+
+// UnmarshalJSONWithPolicy is like UnmarshalJSON but resolves a key occurring more than once in the
+// object according to policy
+func (m *StringMap) UnmarshalJSONWithPolicy(b []byte, policy DuplicateKeyPolicy) error {
+	return m.decode(json.NewDecoder(bytes.NewReader(b)), policy, DecodeLimits{})
+}
+
+// Decode reads a JSON object from r like UnmarshalJSON does, without reading all input up front
+// Like UnmarshalJSON, any data following the object is an error, so r is read until EOF
+func (m *StringMap) Decode(r io.Reader) error {
+	return m.decode(json.NewDecoder(r), OverwriteDuplicates, DecodeLimits{})
+}
+
+// DecodeWithLimits is like Decode but returns an error once the input exceeds limits
+// Reading stops as soon as more than MaxBytes are read or the object has more than MaxEntries
+// members; a key or value exceeding its maximum length is reported after it has been decoded.
+// On error the map holds the entries decoded so far
+func (m *StringMap) DecodeWithLimits(r io.Reader, limits DecodeLimits) error {
+	if limits.MaxBytes > 0 {
+		r = &limitedReader{r: r, max: limits.MaxBytes, remaining: limits.MaxBytes}
+	}
+	return m.decode(json.NewDecoder(r), OverwriteDuplicates, limits)
+}
+
+// limitedReader reads from r, returning an error once more than max bytes have been read
+// Unlike io.LimitReader it does not report exceeding the limit as EOF, which would hide the cause
+type limitedReader struct {
+	r         io.Reader
+	max       int64
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// read one byte beyond the limit to tell input of exactly max bytes from longer input
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("input exceeds %d bytes", l.max)
+	}
+	return n, err
+}
+
+// decode sets the key/value pairs of the JSON object read from d, which must be all of its input
+// A key occurring more than once in the object is resolved according to policy
+func (m *StringMap) decode(d *json.Decoder, policy DuplicateKeyPolicy, limits DecodeLimits) error {
+	if policy < OverwriteDuplicates || policy > RejectDuplicates {
+		return fmt.Errorf("invalid duplicate key policy %d", int(policy))
+	}
 
 	// start of object
 	if t, err := d.Token(); err != nil {
@@ -100,7 +1906,15 @@ func (m *StringMap) UnmarshalJSON(b []byte) error {
 	}
 
 	// key/value pairs
-	for d.More() {
+	var seen map[string]struct{}
+	if policy != OverwriteDuplicates {
+		seen = make(map[string]struct{})
+	}
+	for n := 1; d.More(); n++ {
+		if limits.MaxEntries > 0 && n > limits.MaxEntries {
+			return fmt.Errorf("more than %d entries", limits.MaxEntries)
+		}
+
 		tKey, err := d.Token()
 		if err != nil {
 			return err
@@ -115,7 +1929,30 @@ func (m *StringMap) UnmarshalJSON(b []byte) error {
 			return fmt.Errorf("invalid value type %T", tVal)
 		}
 
-		m.Set(tKey.(string), sVal)
+		key := tKey.(string)
+		if limits.MaxKeyLen > 0 && len(key) > limits.MaxKeyLen {
+			return fmt.Errorf("key of %d bytes exceeds maximum length of %d", len(key), limits.MaxKeyLen)
+		}
+		if limits.MaxValueLen > 0 && len(sVal) > limits.MaxValueLen {
+			return fmt.Errorf("value of key %q exceeds maximum length of %d", key, limits.MaxValueLen)
+		}
+
+		if _, dup := seen[key]; !dup {
+			if seen != nil {
+				seen[key] = struct{}{}
+			}
+			m.Set(key, sVal)
+			continue
+		}
+
+		switch policy {
+		case KeepFirst:
+		case KeepLast:
+			m.Set(key, sVal)
+			m.MoveToBack(key)
+		case RejectDuplicates:
+			return fmt.Errorf("duplicate key %q", key)
+		}
 	}
 
 	// end of object
@@ -139,5 +1976,7 @@ func (m StringMap) Less(i, j int) bool { return m.values[m.keys[i]] < m.values[m
 
 // Swap is part of sort.Interface
 func (m StringMap) Swap(i, j int) {
+	m.mutate()
+
 	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
 }
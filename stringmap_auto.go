@@ -0,0 +1,94 @@
+package orderedmap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// DecodeAuto reads a JSON object, a YAML mapping or key=value lines from r, detecting the format
+// from the input, and sets the decoded entries in order. The format is detected as follows:
+//
+//   - JSON when the input starts with '{'
+//   - YAML when the first line is the document marker ---, or the first line which is neither
+//     blank nor a # comment holds ':' before any '='
+//   - key=value lines as read by UnmarshalText when that line holds '=' before any ':'; lines starting
+//     with # are skipped as comments
+//
+// Any other input is an error. Like Merge, existing keys keep their position; on error the map is
+// left unchanged. Without YAML support, built with the orderedmap_noyaml tag, YAML input is an error
+func (m *StringMap) DecodeAuto(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var decoded StringMap
+	switch detectFormat(b) {
+	case formatJSON:
+		err = decoded.UnmarshalJSON(b)
+	case formatYAML:
+		err = decoded.decodeYAML(b)
+	case formatText:
+		err = decoded.UnmarshalText(stripComments(b))
+	default:
+		err = errors.New("unable to detect format of input")
+	}
+	if err != nil {
+		return err
+	}
+
+	m.Merge(decoded)
+	return nil
+}
+
+// Formats recognized by DecodeAuto
+const (
+	formatUnknown = iota
+	formatJSON
+	formatYAML
+	formatText
+)
+
+// detectFormat returns the format of b as described by DecodeAuto
+func detectFormat(b []byte) int {
+	trimmed := bytes.TrimSpace(b)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return formatJSON
+	}
+
+	for i, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if i == 0 && (bytes.Equal(line, []byte("---")) || bytes.HasPrefix(line, []byte("--- "))) {
+			return formatYAML
+		}
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		colon, eq := bytes.IndexByte(line, ':'), bytes.IndexByte(line, '=')
+		switch {
+		case colon >= 0 && (eq < 0 || colon < eq):
+			return formatYAML
+		case eq >= 0:
+			return formatText
+		}
+		return formatUnknown
+	}
+
+	return formatUnknown
+}
+
+// stripComments returns b without the lines whose first non-blank character is '#'
+func stripComments(b []byte) []byte {
+	lines := bytes.Split(b, []byte("\n"))
+	kept := lines[:0]
+	for _, line := range lines {
+		if trimmed := bytes.TrimSpace(line); len(trimmed) == 0 || trimmed[0] != '#' {
+			kept = append(kept, line)
+		}
+	}
+
+	return bytes.Join(kept, []byte("\n"))
+}
@@ -0,0 +1,48 @@
+package orderedmap_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestStringMap_DecodeAuto(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"json", ` {"second":"2","url":"http://example.com/?a=b"}`},
+		{"text", "second=2\nurl=http://example.com/?a=b\n"},
+		{"text with comments", "# settings\n\nsecond=2\n  # the address\nurl=http://example.com/?a=b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			stringmap.Set("url", "old")
+			stringmap.Set("first", "1")
+			if err := stringmap.DecodeAuto(strings.NewReader(test.input)); err != nil {
+				t.Fatal(err)
+			}
+
+			assertEntries(t, stringmap, []struct{ k, v string }{
+				{"url", "http://example.com/?a=b"},
+				{"first", "1"},
+				{"second", "2"},
+			})
+		})
+	}
+}
+
+func TestStringMap_DecodeAutoErrors(t *testing.T) {
+	for _, input := range []string{"", "  \n", "# only a comment\n", "just words\n", `{"a":1}`, "a=1\nno separator\n"} {
+		var stringmap StringMap
+		stringmap.Set("first", "1")
+		if err := stringmap.DecodeAuto(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for input %q", input)
+		}
+		assertEntries(t, stringmap, []struct{ k, v string }{
+			{"first", "1"},
+		})
+	}
+}
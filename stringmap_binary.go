@@ -0,0 +1,154 @@
+package orderedmap
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// AppendWire appends the wire encoding of the map to dst and returns the extended buffer
+//
+// The wire format is a sequence of length-delimited fields, alternating key and value, in order.
+// Each field is the length of its UTF-8 bytes as an unsigned base 128 varint, as used by
+// Protocol Buffers, followed by the bytes themselves:
+//
+//	key1-length key1 value1-length value1 key2-length key2 ...
+//
+// An empty map encodes as zero bytes
+func (m StringMap) AppendWire(dst []byte) []byte {
+	for _, key := range m.keys {
+		dst = appendField(dst, key)
+		dst = appendField(dst, m.values[key])
+	}
+
+	return dst
+}
+
+// ParseWire sets the key/value pairs read from the wire encoding in b
+// See AppendWire for the format
+func (m *StringMap) ParseWire(b []byte) error {
+	for len(b) > 0 {
+		key, n, err := readField(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		if len(b) == 0 {
+			return errors.New("missing value for key")
+		}
+		value, n, err := readField(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		m.Set(key, value)
+	}
+
+	return nil
+}
+
+// appendField appends s prefixed by its varint length to dst
+func appendField(dst []byte, s string) []byte {
+	var length [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(length[:], uint64(len(s)))
+	dst = append(dst, length[:n]...)
+
+	return append(dst, s...)
+}
+
+// readField reads a varint length prefixed string from b
+// It returns the string and the number of bytes read
+func readField(b []byte) (string, int, error) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 {
+		return "", 0, errors.New("invalid field length")
+	}
+	if length > uint64(len(b)-n) {
+		return "", 0, errors.New("unexpected end of input")
+	}
+	end := n + int(length)
+
+	return string(b[n:end]), end, nil
+}
+
+// GobEncode implements gob.GobEncoder using the wire encoding of AppendWire
+func (m StringMap) GobEncode() ([]byte, error) {
+	return m.AppendWire(nil), nil
+}
+
+// GobDecode implements gob.GobDecoder
+// The contents of the map are replaced by the decoded entries; on error the map is left unchanged
+func (m *StringMap) GobDecode(b []byte) error {
+	var decoded StringMap
+	if err := decoded.ParseWire(b); err != nil {
+		return err
+	}
+
+	m.ReplaceAll(decoded.Pairs())
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+// The encoding is the number of entries as an unsigned varint followed by the wire encoding of AppendWire
+func (m StringMap) MarshalBinary() ([]byte, error) {
+	var count [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(count[:], uint64(len(m.keys)))
+
+	return m.AppendWire(append(make([]byte, 0, n+m.wireSize()), count[:n]...)), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+// The contents of the map are replaced by the decoded entries; on error the map is left unchanged
+func (m *StringMap) UnmarshalBinary(b []byte) error {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errors.New("invalid entry count")
+	}
+	b = b[n:]
+	// every entry takes at least two bytes; this guards the allocation below
+	if count > uint64(len(b)/2) {
+		return errors.New("unexpected end of input")
+	}
+
+	entries := make([]Entry, count)
+	for i := range entries {
+		key, n, err := readField(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		value, n, err := readField(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		entries[i] = Entry{Key: key, Value: value}
+	}
+	if len(b) > 0 {
+		return errors.New("unexpected data after last entry")
+	}
+
+	m.ReplaceAll(entries)
+	return nil
+}
+
+// wireSize returns the length of the wire encoding of the map
+func (m StringMap) wireSize() int {
+	size := 0
+	for _, key := range m.keys {
+		size += fieldSize(key) + fieldSize(m.values[key])
+	}
+	return size
+}
+
+// fieldSize returns the length of s encoded by appendField
+func fieldSize(s string) int {
+	n := 1
+	for length := uint64(len(s)); length >= 0x80; length >>= 7 {
+		n++
+	}
+	return n + len(s)
+}
@@ -0,0 +1,166 @@
+package orderedmap_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestStringMap_AppendWire(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("key", "")
+
+	actually := stringmap.AppendWire([]byte{0xff})
+
+	expected := []byte{0xff, 1, 'a', 1, '1', 3, 'k', 'e', 'y', 0}
+	if !bytes.Equal(actually, expected) {
+		t.Errorf("expected wire encoding %v, got %v", expected, actually)
+	}
+}
+
+func TestStringMap_ParseWireRoundTrip(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("", "empty key")
+	stringmap.Set("unicode ✓", string(bytes.Repeat([]byte("long"), 100)))
+
+	var parsed StringMap
+	if err := parsed.ParseWire(stringmap.AppendWire(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, parsed, []struct{ k, v string }{
+		{"key one", "value 1"},
+		{"", "empty key"},
+		{"unicode ✓", string(bytes.Repeat([]byte("long"), 100))},
+	})
+}
+
+func TestStringMap_ParseWireErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"missing value", []byte{1, 'a'}},
+		{"truncated key", []byte{3, 'a'}},
+		{"truncated value", []byte{1, 'a', 2, 'b'}},
+		{"invalid length", []byte{0xff}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			if err := stringmap.ParseWire(test.input); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestStringMap_Gob(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "1")
+	stringmap.Set("third", "3")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(stringmap); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded StringMap
+	decoded.Set("stale", "entry")
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, decoded, []struct{ k, v string }{
+		{"second", "2"},
+		{"first", "1"},
+		{"third", "3"},
+	})
+}
+
+func TestStringMap_MarshalBinary(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("b", "2")
+	stringmap.Set("a", "")
+
+	b, err := stringmap.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{2, 1, 'b', 1, '2', 1, 'a', 0}; !bytes.Equal(b, expected) {
+		t.Errorf("expected %v, got %v", expected, b)
+	}
+
+	var decoded StringMap
+	decoded.Set("stale", "entry")
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, decoded, []struct{ k, v string }{
+		{"b", "2"},
+		{"a", ""},
+	})
+}
+
+func TestStringMap_UnmarshalBinaryErrors(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("key two", "value 2")
+	valid, _ := stringmap.MarshalBinary()
+
+	for i := 0; i < len(valid); i++ {
+		var decoded StringMap
+		if err := decoded.UnmarshalBinary(valid[:i]); err == nil {
+			t.Errorf("expected error for input truncated to %d bytes", i)
+		}
+		if decoded.Len() != 0 {
+			t.Errorf("expected map to be left unchanged for input truncated to %d bytes", i)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"trailing data", append(append([]byte{}, valid...), 0)},
+		{"count too large", []byte{0xff, 0xff, 0xff, 0xff, 0x0f, 1, 'a', 0}},
+		{"invalid count", []byte{0xff}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var decoded StringMap
+			if err := decoded.UnmarshalBinary(test.input); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func BenchmarkStringMap_MarshalBinary(b *testing.B) {
+	var stringmap StringMap
+	for _, key := range benchmarkKeys {
+		stringmap.Set(key, "value")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = stringmap.MarshalBinary()
+	}
+}
+
+func BenchmarkStringMap_MarshalJSONLarge(b *testing.B) {
+	var stringmap StringMap
+	for _, key := range benchmarkKeys {
+		stringmap.Set(key, "value")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = stringmap.MarshalJSON()
+	}
+}
@@ -0,0 +1,29 @@
+package orderedmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringMap_DuplicateKeyPositions(t *testing.T) {
+	m := StringMap{
+		keys:   []string{"a", "b", "a", "c", "b", "a"},
+		values: map[string]string{"a": "1", "b": "2", "c": "3"},
+	}
+
+	expected := map[string][]int{
+		"a": {0, 2, 5},
+		"b": {1, 4},
+	}
+	if actually := m.DuplicateKeyPositions(); !reflect.DeepEqual(actually, expected) {
+		t.Errorf("expected duplicates %v, got %v", expected, actually)
+	}
+
+	var valid StringMap
+	valid.Set("a", "1")
+	valid.Set("b", "2")
+	valid.Set("a", "3")
+	if actually := valid.DuplicateKeyPositions(); actually != nil {
+		t.Errorf("expected no duplicates, got %v", actually)
+	}
+}
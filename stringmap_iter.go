@@ -0,0 +1,42 @@
+//go:build go1.23
+// +build go1.23
+
+package orderedmap
+
+import "iter"
+
+// All returns an iterator over the key/value pairs in order
+// Like ranging over a built-in map, the effect of modifying the map during iteration is undefined
+func (m StringMap) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, key := range m.keys {
+			if !yield(key, m.values[key]) {
+				return
+			}
+		}
+	}
+}
+
+// KeysSeq returns an iterator over the keys in order
+// Unlike Keys it does not copy the keys, so modifying the map during iteration is undefined
+func (m StringMap) KeysSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, key := range m.keys {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// ValuesSeq returns an iterator over the values in order
+// Like ranging over a built-in map, the effect of modifying the map during iteration is undefined
+func (m StringMap) ValuesSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, key := range m.keys {
+			if !yield(m.values[key]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+//go:build go1.23
+// +build go1.23
+
+package orderedmap_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestStringMap_All(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	var ranged StringMap
+	for k, v := range stringmap.All() {
+		ranged.Set(k, v)
+	}
+	assertEntries(t, ranged, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+		{"third", "3"},
+	})
+
+	// Breaking stops the iteration
+	var keys []string
+	for k := range stringmap.All() {
+		keys = append(keys, k)
+		if k == "second" {
+			break
+		}
+	}
+	if fmt.Sprint(keys) != "[first second]" {
+		t.Errorf("expected keys [first second], got %v", keys)
+	}
+}
+
+func TestStringMap_KeysSeq(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	var keys []string
+	for k := range stringmap.KeysSeq() {
+		keys = append(keys, k)
+	}
+	if fmt.Sprint(keys) != "[first second third]" {
+		t.Errorf("expected keys [first second third], got %v", keys)
+	}
+
+	for k := range stringmap.KeysSeq() {
+		if k != "first" {
+			t.Errorf("expected iteration to stop after first key, got %q", k)
+		}
+		break
+	}
+}
+
+func TestStringMap_ValuesSeq(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	var values []string
+	for v := range stringmap.ValuesSeq() {
+		values = append(values, v)
+		if v == "2" {
+			break
+		}
+	}
+	if fmt.Sprint(values) != "[1 2]" {
+		t.Errorf("expected values [1 2], got %v", values)
+	}
+
+	var empty StringMap
+	for range empty.ValuesSeq() {
+		t.Errorf("expected no values")
+	}
+}
+
+func ExampleStringMap_All() {
+	var m StringMap
+	m.Set("first", "1")
+	m.Set("second", "2")
+	m.Set("third", "3")
+
+	for k, v := range m.All() {
+		fmt.Println(k, "=", v)
+	}
+
+	// Output:
+	// first = 1
+	// second = 2
+	// third = 3
+}
@@ -0,0 +1,11 @@
+//go:build orderedmap_noyaml
+// +build orderedmap_noyaml
+
+package orderedmap
+
+import "errors"
+
+// decodeYAML reports that YAML support is left out of this build
+func (m *StringMap) decodeYAML(b []byte) error {
+	return errors.New("YAML support is not built in")
+}
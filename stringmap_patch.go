@@ -0,0 +1,95 @@
+package orderedmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Patch operations as written by EncodePatch
+const (
+	patchSet    = "+"
+	patchDelete = "-"
+	patchMove   = "~"
+)
+
+// EncodePatch writes the changes which turn base into m to w
+//
+// The patch is a stream of JSON arrays, one per line, each holding a single operation:
+//
+//	["-","key"]          delete key
+//	["+","key","value"]  set key to value, appending a new key
+//	["~","key","2"]      move key to position 2, counting from 0
+//
+// All deletions come first, in order of base, followed by the keys which were added or modified,
+// in order of m. When the resulting order differs from m, moves follow which put the keys in the
+// order of m; they are to be applied one after another. Applying the patch to base with
+// ApplyPatchStream results in a map equal to m, including its order
+func (m StringMap) EncodePatch(w io.Writer, base StringMap) error {
+	e := json.NewEncoder(w)
+	patched := base.Clone()
+	for _, key := range base.keys {
+		if _, exists := m.values[key]; !exists {
+			if err := e.Encode([]string{patchDelete, key}); err != nil {
+				return err
+			}
+		}
+	}
+	patched.retain(func(key, _ string) bool {
+		_, exists := m.values[key]
+		return exists
+	})
+
+	for _, key := range m.keys {
+		if baseValue, exists := base.values[key]; !exists || baseValue != m.values[key] {
+			if err := e.Encode([]string{patchSet, key, m.values[key]}); err != nil {
+				return err
+			}
+			patched.Set(key, m.values[key])
+		}
+	}
+
+	moves, err := patched.ReorderSteps(m)
+	if err != nil {
+		return err
+	}
+	for _, move := range moves {
+		if err := e.Encode([]string{patchMove, patched.keys[move.From], strconv.Itoa(move.To)}); err != nil {
+			return err
+		}
+		patched.move(move.From, move.To)
+	}
+
+	return nil
+}
+
+// ApplyPatchStream applies the operations of a patch as written by EncodePatch, in order
+// Operations read before an invalid one has been encountered remain applied
+func (m *StringMap) ApplyPatchStream(r io.Reader) error {
+	d := json.NewDecoder(r)
+	for {
+		var op []string
+		if err := d.Decode(&op); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch {
+		case len(op) == 3 && op[0] == patchSet:
+			m.Set(op[1], op[2])
+		case len(op) == 2 && op[0] == patchDelete:
+			m.Delete(op[1])
+		case len(op) == 3 && op[0] == patchMove:
+			from := m.IndexOf(op[1])
+			to, err := strconv.Atoi(op[2])
+			if from < 0 || err != nil || to < 0 || to >= len(m.keys) {
+				return fmt.Errorf("invalid patch operation %q", op)
+			}
+			m.move(from, to)
+		default:
+			return fmt.Errorf("invalid patch operation %q", op)
+		}
+	}
+}
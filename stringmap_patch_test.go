@@ -0,0 +1,118 @@
+package orderedmap_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestStringMap_EncodePatch(t *testing.T) {
+	var base, stringmap StringMap
+	base.Set("host", "localhost")
+	base.Set("user", "root")
+	base.Set("port", "80")
+	base.Set("debug", "true")
+	stringmap.Set("host", "localhost")
+	stringmap.Set("timeout", "5s")
+	stringmap.Set("port", "8080")
+	stringmap.Set("name", "db")
+
+	var patch bytes.Buffer
+	if err := stringmap.EncodePatch(&patch, base); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `["-","user"]
+["-","debug"]
+["+","timeout","5s"]
+["+","port","8080"]
+["+","name","db"]
+["~","timeout","1"]
+`
+	if patch.String() != expected {
+		t.Errorf("expected patch\n%s\ngot\n%s", expected, patch.String())
+	}
+
+	if err := base.ApplyPatchStream(&patch); err != nil {
+		t.Fatal(err)
+	}
+	if !base.Equal(stringmap) {
+		t.Errorf("expected patched base %q to equal %q", base.Pairs(), stringmap.Pairs())
+	}
+}
+
+func TestStringMap_EncodePatchOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		base, next []string
+	}{
+		{"reordered", []string{"a", "b", "c", "d"}, []string{"d", "b", "a", "c"}},
+		{"reversed", []string{"a", "b", "c", "d"}, []string{"d", "c", "b", "a"}},
+		{"inserted in the middle", []string{"a", "b", "c"}, []string{"a", "x", "b", "c"}},
+		{"deleted and inserted", []string{"a", "b", "c"}, []string{"y", "c", "x", "a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var base, stringmap StringMap
+			for _, key := range test.base {
+				base.Set(key, "value of "+key)
+			}
+			for _, key := range test.next {
+				stringmap.Set(key, "value of "+key)
+			}
+
+			var patch bytes.Buffer
+			if err := stringmap.EncodePatch(&patch, base); err != nil {
+				t.Fatal(err)
+			}
+			if patch.Len() == 0 {
+				t.Fatal("expected a non-empty patch")
+			}
+			if err := base.ApplyPatchStream(&patch); err != nil {
+				t.Fatal(err)
+			}
+			if !base.Equal(stringmap) {
+				t.Errorf("expected patched base %q to equal %q", base.Keys(), stringmap.Keys())
+			}
+		})
+	}
+}
+
+func TestStringMap_EncodePatchUnchanged(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("host", "localhost")
+
+	var patch bytes.Buffer
+	if err := stringmap.EncodePatch(&patch, stringmap); err != nil {
+		t.Fatal(err)
+	}
+	if patch.Len() != 0 {
+		t.Errorf("expected empty patch, got %s", patch.String())
+	}
+}
+
+func TestStringMap_ApplyPatchStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"invalid json", `["+","key"`},
+		{"not an array", `{"key":"value"}`},
+		{"unknown operation", `["*","key"]`},
+		{"set without value", `["+","key"]`},
+		{"delete with value", `["-","key","value"]`},
+		{"move missing key", `["~","key","0"]`},
+		{"move out of range", `["+","key","value"]` + "\n" + `["~","key","1"]`},
+		{"move to invalid position", `["+","key","value"]` + "\n" + `["~","key","first"]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			if err := stringmap.ApplyPatchStream(strings.NewReader(test.input)); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package orderedmap
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Scan implements sql.Scanner for JSON columns
+// src may be a JSON object as []byte or string, which replaces the contents of the map in order, or nil,
+// which empties the map
+func (m *StringMap) Scan(src interface{}) error {
+	var decoded StringMap
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		if err := decoded.UnmarshalJSON(src); err != nil {
+			return err
+		}
+	case string:
+		if err := decoded.UnmarshalJSON([]byte(src)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported Scan type %T", src)
+	}
+
+	m.ReplaceAll(decoded.Pairs())
+	return nil
+}
+
+// JSONValue is a StringMap to be stored in a JSON column
+// As StringMap.Value already retrieves a value by key, convert a map to this type to pass it as a query
+// argument; the JSON object it stores keeps the order of the map
+//
+//	_, err := db.Exec("UPDATE t SET attrs = $1", orderedmap.JSONValue(m))
+//
+// Read it back by scanning into a *StringMap
+type JSONValue StringMap
+
+// Value implements driver.Valuer, returning the JSON encoding of the map
+func (v JSONValue) Value() (driver.Value, error) {
+	return StringMap(v).MarshalJSON()
+}
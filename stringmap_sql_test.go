@@ -0,0 +1,62 @@
+package orderedmap_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+var _ sql.Scanner = (*StringMap)(nil)
+var _ driver.Valuer = JSONValue{}
+
+func TestStringMap_Scan(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      interface{}
+		expected []struct{ k, v string }
+	}{
+		{"bytes", []byte(`{"b":"2","a":"1"}`), []struct{ k, v string }{{"b", "2"}, {"a", "1"}}},
+		{"string", `{"a":"1","b":"2"}`, []struct{ k, v string }{{"a", "1"}, {"b", "2"}}},
+		{"nil", nil, []struct{ k, v string }{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			stringmap.Set("stale", "entry")
+			if err := stringmap.Scan(test.src); err != nil {
+				t.Fatal(err)
+			}
+			assertEntries(t, stringmap, test.expected)
+		})
+	}
+
+	var stringmap StringMap
+	if err := stringmap.Scan(42); err == nil {
+		t.Errorf("expected error for unsupported type")
+	}
+	if err := stringmap.Scan(`["a"]`); err == nil {
+		t.Errorf("expected error for invalid JSON object")
+	}
+}
+
+func TestJSONValue_Value(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "1")
+
+	value, err := JSONValue(stringmap).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned StringMap
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, scanned, []struct{ k, v string }{
+		{"second", "2"},
+		{"first", "1"},
+	})
+}
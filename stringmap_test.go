@@ -2,9 +2,16 @@ package orderedmap_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	. "github.com/ferdypruis/orderedmap"
@@ -277,6 +284,2517 @@ func TestStringMap_KeysImmutable(t *testing.T) {
 	}
 }
 
+func TestStringMap_Update(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("counter", "41")
+
+	increment := func(old string, existed bool) string {
+		if !existed {
+			return "1"
+		}
+		n, _ := strconv.Atoi(old)
+		return strconv.Itoa(n + 1)
+	}
+	stringmap.Update("counter", increment)
+	stringmap.Update("new", increment)
+
+	expected := []struct {
+		k string
+		v string
+	}{
+		{"first", "1"},
+		{"counter", "42"},
+		{"new", "1"},
+	}
+
+	assertEntries(t, stringmap, expected)
+}
+
+func TestStringMap_MapKeys(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "val2")
+	stringmap.Set("key2", "a third value")
+
+	mapped, err := stringmap.MapKeys(func(key string) string {
+		return "ns." + key
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, mapped, []struct{ k, v string }{
+		{"ns.key one", "value 1"},
+		{"ns.otherkey", "val2"},
+		{"ns.key2", "a third value"},
+	})
+
+	// The receiver is left untouched
+	if keys := stringmap.Keys(); keys[0] != "key one" {
+		t.Errorf("expected receiver to be unchanged, got keys %q", keys)
+	}
+}
+
+func TestStringMap_MapKeysCollision(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("key2", "val2")
+
+	_, err := stringmap.MapKeys(func(key string) string {
+		return key[:3]
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if expected := `keys "key one" and "key2" both map to "key"`; err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err)
+	}
+}
+
+func TestStringMap_PrefixKeys(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "val2")
+
+	stringmap.PrefixKeys("ns.")
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"ns.key one", "value 1"},
+		{"ns.otherkey", "val2"},
+	})
+	if _, ok := stringmap.Value("key one"); ok {
+		t.Errorf("expected old key %q to be gone", "key one")
+	}
+}
+
+func TestStringMap_SuffixKeys(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "val2")
+
+	stringmap.SuffixKeys(".old")
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"key one.old", "value 1"},
+		{"otherkey.old", "val2"},
+	})
+
+	// An empty map stays empty
+	var empty StringMap
+	empty.SuffixKeys(".old")
+	if empty.Len() != 0 {
+		t.Errorf("expected empty map, got %d items", empty.Len())
+	}
+}
+
+func TestStringMap_HeadTail(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	tests := []struct {
+		name     string
+		actually StringMap
+		expected []struct{ k, v string }
+	}{
+		{"head", stringmap.Head(2), []struct{ k, v string }{{"first", "1"}, {"second", "2"}}},
+		{"head all", stringmap.Head(5), []struct{ k, v string }{{"first", "1"}, {"second", "2"}, {"third", "3"}}},
+		{"head none", stringmap.Head(-1), nil},
+		{"tail", stringmap.Tail(2), []struct{ k, v string }{{"second", "2"}, {"third", "3"}}},
+		{"tail all", stringmap.Tail(3), []struct{ k, v string }{{"first", "1"}, {"second", "2"}, {"third", "3"}}},
+		{"tail none", stringmap.Tail(0), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertEntries(t, test.actually, test.expected)
+		})
+	}
+
+	if stringmap.Len() != 3 {
+		t.Errorf("expected receiver to keep 3 items, got %d", stringmap.Len())
+	}
+}
+
+func TestStringMap_CoalesceGroup(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("colorful", "no")
+	stringmap.Set("color.dark", "")
+	stringmap.Set("size.default", "")
+	stringmap.Set("color.default", "blue")
+	stringmap.Set("color.light", "white")
+
+	tests := []struct {
+		prefix string
+		value  string
+		ok     bool
+	}{
+		{"color", "blue", true},
+		{"size", "", true},
+		{"shape", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.prefix, func(t *testing.T) {
+			value, ok := stringmap.CoalesceGroup(test.prefix, ".")
+			if value != test.value || ok != test.ok {
+				t.Errorf("expected %q, %t, got %q, %t", test.value, test.ok, value, ok)
+			}
+		})
+	}
+}
+
+func TestStringMap_ValidateKeys(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("host", "localhost")
+	stringmap.Set("debug", "true")
+	stringmap.Set("port", "8080")
+	stringmap.Set("colour", "red")
+
+	if err := stringmap.ValidateKeys([]string{"host", "port"}, []string{"debug", "colour", "user"}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := stringmap.ValidateKeys([]string{"user", "host", "password"}, []string{"port"})
+	keysErr, ok := err.(*KeysError)
+	if !ok {
+		t.Fatalf("expected *KeysError, got %#v", err)
+	}
+	if fmt.Sprint(keysErr.Missing) != "[user password]" {
+		t.Errorf("expected missing keys [user password], got %v", keysErr.Missing)
+	}
+	if fmt.Sprint(keysErr.Unexpected) != "[debug colour]" {
+		t.Errorf("expected unexpected keys [debug colour], got %v", keysErr.Unexpected)
+	}
+	if expected := `missing required keys ["user" "password"]; unexpected keys ["debug" "colour"]`; err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err)
+	}
+}
+
+func TestStringMap_Stream(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	ch := stringmap.Stream()
+	// Changes after the call are not seen on the channel
+	stringmap.Set("fourth", "4")
+
+	var streamed StringMap
+	for entry := range ch {
+		streamed.Set(entry.Key, entry.Value)
+	}
+
+	assertEntries(t, streamed, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+		{"third", "3"},
+	})
+}
+
+func TestStringMap_StreamContext(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := stringmap.StreamContext(ctx)
+
+	if entry := <-ch; entry.Key != "first" || entry.Value != "1" {
+		t.Errorf("expected first entry, got %#v", entry)
+	}
+	cancel()
+
+	// The channel must be closed after cancellation, possibly after one more entry
+	// already being sent
+	received := 0
+	for range ch {
+		received++
+	}
+	if received > 1 {
+		t.Errorf("expected streaming to stop after cancel, received %d more entries", received)
+	}
+}
+
+func TestStringMap_ReplaceAll(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "val2")
+
+	stringmap.ReplaceAll([]Entry{
+		{"new", "a"},
+		{"otherkey", "b"},
+		{"new", "c"},
+	})
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"new", "c"},
+		{"otherkey", "b"},
+	})
+	if _, ok := stringmap.Value("key one"); ok {
+		t.Errorf("expected key %q to be removed", "key one")
+	}
+
+	// Replacing the contents of a zero value
+	var empty StringMap
+	empty.ReplaceAll([]Entry{{"first", "1"}})
+	assertEntries(t, empty, []struct{ k, v string }{{"first", "1"}})
+}
+
+func TestCommonKeys(t *testing.T) {
+	var first, second, third StringMap
+	first.Set("id", "1")
+	first.Set("name", "one")
+	first.Set("colour", "red")
+	first.Set("size", "L")
+	second.Set("size", "M")
+	second.Set("id", "2")
+	second.Set("name", "two")
+	third.Set("name", "three")
+	third.Set("weight", "3")
+	third.Set("size", "S")
+
+	tests := []struct {
+		name     string
+		maps     []StringMap
+		expected []string
+	}{
+		{"no maps", nil, []string{}},
+		{"single map", []StringMap{first}, []string{"id", "name", "colour", "size"}},
+		{"two maps", []StringMap{first, second}, []string{"id", "name", "size"}},
+		{"three maps", []StringMap{first, second, third}, []string{"name", "size"}},
+		{"order of first map", []StringMap{third, first}, []string{"name", "size"}},
+		{"empty map", []StringMap{first, {}}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actually := CommonKeys(test.maps...)
+			if actually == nil || fmt.Sprint(actually) != fmt.Sprint(test.expected) {
+				t.Errorf("expected keys %q, got %#v", test.expected, actually)
+			}
+		})
+	}
+}
+
+func TestStringMap_Render(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+
+	actually, err := stringmap.Render("<li>{{.Key}}: {{.Value}}</li>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<li>first: 1</li><li>second: 2</li>"; actually != expected {
+		t.Errorf("expected %q, got %q", expected, actually)
+	}
+
+	if _, err := stringmap.Render("{{.Key"); err == nil {
+		t.Errorf("expected parse error")
+	}
+	if _, err := stringmap.Render("{{.Unknown}}"); err == nil {
+		t.Errorf("expected execution error")
+	}
+}
+
+func TestStringMap_UniqueValues(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("b", "2")
+	stringmap.Set("c", "1")
+	stringmap.Set("d", "3")
+	stringmap.Set("e", "2")
+	stringmap.Set("f", "1")
+
+	if removed := stringmap.UniqueValues(); removed != 3 {
+		t.Errorf("expected 3 entries to be removed, got %d", removed)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"a", "1"},
+		{"b", "2"},
+		{"d", "3"},
+	})
+	if _, ok := stringmap.Value("c"); ok {
+		t.Errorf("expected key %q to be removed", "c")
+	}
+}
+
+func TestStringMap_EncodeSharded(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+	stringmap.Set("fourth", "4")
+
+	var shard1, shard2, shard3 bytes.Buffer
+	if err := stringmap.EncodeSharded([]io.Writer{&shard1, &shard2, &shard3}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`{"first":"1","fourth":"4"}`,
+		`{"second":"2"}`,
+		`{"third":"3"}`,
+	}
+	for i, shard := range []bytes.Buffer{shard1, shard2, shard3} {
+		if shard.String() != expected[i] {
+			t.Errorf("expected shard %d to be %s, got %s", i, expected[i], shard.String())
+		}
+	}
+
+	if err := stringmap.EncodeSharded(nil); err == nil {
+		t.Errorf("expected error without writers")
+	}
+}
+
+func TestStringMap_Changelog(t *testing.T) {
+	var before, after StringMap
+	before.Set("host", "localhost")
+	before.Set("user", "root")
+	before.Set("port", "80")
+	before.Set("debug", "true")
+	after.Set("colour", "red")
+	after.Set("port", "8080")
+	after.Set("host", "localhost")
+	after.Set("timeout", "5s")
+	after.Set("name", "db")
+
+	expected := []Change{
+		{Kind: Removed, Key: "user", OldValue: "root"},
+		{Kind: Modified, Key: "port", OldValue: "80", NewValue: "8080"},
+		{Kind: Removed, Key: "debug", OldValue: "true"},
+		{Kind: Added, Key: "colour", NewValue: "red"},
+		{Kind: Added, Key: "timeout", NewValue: "5s"},
+		{Kind: Added, Key: "name", NewValue: "db"},
+	}
+
+	actually := before.Changelog(after)
+	if len(actually) != len(expected) {
+		t.Fatalf("expected %d changes, got %d; %v", len(expected), len(actually), actually)
+	}
+	for i, change := range actually {
+		if change != expected[i] {
+			t.Errorf("expected change %d to be %v, got %v", i, expected[i], change)
+		}
+	}
+
+	if changes := before.Changelog(before); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+	if Modified.String() != "modified" {
+		t.Errorf("expected kind %q, got %q", "modified", Modified)
+	}
+}
+
+func TestStringMap_SetSorted(t *testing.T) {
+	byValue := func(aKey, aVal, bKey, bVal string) bool {
+		return aVal < bVal
+	}
+
+	var stringmap StringMap
+	stringmap.SetSorted("c", "3", byValue)
+	stringmap.SetSorted("a", "1", byValue)
+	stringmap.SetSorted("e", "5", byValue)
+	stringmap.SetSorted("b", "2", byValue)
+	stringmap.SetSorted("other b", "2", byValue)
+	stringmap.SetSorted("d", "4", byValue)
+	// Existing key is updated in place, even if that violates the sort order
+	stringmap.SetSorted("a", "9", byValue)
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"a", "9"},
+		{"b", "2"},
+		{"other b", "2"},
+		{"c", "3"},
+		{"d", "4"},
+		{"e", "5"},
+	})
+}
+
+func TestStringMap_JSONReader(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "<val2>")
+	stringmap.Set("key3", "a third value")
+
+	expected, _ := stringmap.MarshalJSON()
+
+	// Read in tiny chunks to cover entries spanning several reads
+	r := stringmap.JSONReader()
+	var actually []byte
+	p := make([]byte, 3)
+	for {
+		n, err := r.Read(p)
+		actually = append(actually, p[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(actually, expected) {
+		t.Errorf("expected json %s, got %s", expected, actually)
+	}
+
+	var empty StringMap
+	if b, _ := ioutil.ReadAll(empty.JSONReader()); string(b) != "{}" {
+		t.Errorf("expected json {}, got %s", b)
+	}
+}
+
+func TestStringMap_ValuesWithPrefix(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("allow.1", "10.0.0.1")
+	stringmap.Set("deny.1", "0.0.0.0")
+	stringmap.Set("allow.2", "10.0.0.2")
+	stringmap.Set("allow.3", "10.0.0.3")
+
+	actually := stringmap.ValuesWithPrefix("allow.")
+	if expected := "[10.0.0.1 10.0.0.2 10.0.0.3]"; fmt.Sprint(actually) != expected {
+		t.Errorf("expected values %s, got %v", expected, actually)
+	}
+
+	if actually := stringmap.ValuesWithPrefix("none."); actually == nil || len(actually) != 0 {
+		t.Errorf("expected empty slice, got %#v", actually)
+	}
+}
+
+func TestStringMap_Seal(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	if stringmap.Sealed() {
+		t.Errorf("expected map not to be sealed")
+	}
+
+	stringmap.Seal()
+	if !stringmap.Sealed() {
+		t.Errorf("expected map to be sealed")
+	}
+
+	mutations := []struct {
+		name   string
+		mutate func()
+	}{
+		{"Set", func() { stringmap.Set("third", "3") }},
+		{"Set existing", func() { stringmap.Set("first", "one") }},
+		{"Sort", func() { stringmap.Sort(func(s, t string) bool { return s > t }) }},
+		{"SortKeys", func() { stringmap.SortKeys(func(s, t string) bool { return s > t }) }},
+		{"sort.Sort", func() { sort.Sort(sort.Reverse(stringmap)) }},
+		{"PrefixKeys", func() { stringmap.PrefixKeys("ns.") }},
+		{"ReplaceAll", func() { stringmap.ReplaceAll(nil) }},
+		{"UniqueValues", func() { stringmap.UniqueValues() }},
+		{"Delete", func() { stringmap.Delete("first") }},
+		{"Rename", func() { _ = stringmap.Rename("first", "one") }},
+		{"Grow", func() { stringmap.Grow(10) }},
+		{"Compact", func() { stringmap.Compact() }},
+		{"UnmarshalJSON", func() { _ = json.Unmarshal([]byte(`{"third":"3"}`), &stringmap) }},
+	}
+	for _, mutation := range mutations {
+		t.Run(mutation.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			mutation.mutate()
+		})
+	}
+
+	// Reading still works and nothing changed
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+	})
+}
+
+func TestStringMap_ApplyDefaults(t *testing.T) {
+	var defaults StringMap
+	defaults.Set("host", "localhost")
+	defaults.Set("port", "80")
+	defaults.Set("debug", "false")
+
+	var stringmap StringMap
+	stringmap.Set("debug", "true")
+	stringmap.Set("user", "root")
+
+	stringmap.ApplyDefaults(defaults)
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"debug", "true"},
+		{"user", "root"},
+		{"host", "localhost"},
+		{"port", "80"},
+	})
+
+	// Defaults are applied to a zero value as well
+	var empty StringMap
+	empty.ApplyDefaults(defaults)
+	assertEntries(t, empty, []struct{ k, v string }{
+		{"host", "localhost"},
+		{"port", "80"},
+		{"debug", "false"},
+	})
+}
+
+func TestStringMap_UnifiedDiff(t *testing.T) {
+	var before, after StringMap
+	before.Set("host", "localhost")
+	before.Set("user", "root")
+	before.Set("port", "80")
+	before.Set("debug", "true")
+	before.Set("name", "db")
+	after.Set("host", "localhost")
+	after.Set("port", "8080")
+	after.Set("debug", "true")
+	after.Set("name", "db")
+	after.Set("user", "root")
+	after.Set("timeout", "5s")
+
+	expected := ` host=localhost
+-user=root
+-port=80
++port=8080
+ debug=true
+ name=db
++user=root
++timeout=5s
+`
+	if actually := before.UnifiedDiff(after); actually != expected {
+		t.Errorf("expected diff\n%s\ngot\n%s", expected, actually)
+	}
+
+	if actually := before.UnifiedDiff(StringMap{}); actually != "-host=localhost\n-user=root\n-port=80\n-debug=true\n-name=db\n" {
+		t.Errorf("expected all entries removed, got\n%s", actually)
+	}
+}
+
+func TestStringMap_EachChunk(t *testing.T) {
+	var stringmap StringMap
+	for i := 1; i <= 5; i++ {
+		stringmap.Set("key"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+
+	var chunks []string
+	stringmap.EachChunk(2, func(chunk []Entry) bool {
+		chunks = append(chunks, fmt.Sprint(chunk))
+		return true
+	})
+	if expected := "[[{key1 1} {key2 2}] [{key3 3} {key4 4}] [{key5 5}]]"; fmt.Sprint(chunks) != expected {
+		t.Errorf("expected chunks %s, got %s", expected, chunks)
+	}
+
+	// Stop after the first chunk
+	calls := 0
+	stringmap.EachChunk(3, func(chunk []Entry) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+
+	// Without entries or with an invalid size fn is never called
+	var empty StringMap
+	empty.EachChunk(2, func([]Entry) bool { t.Error("unexpected call"); return true })
+	stringmap.EachChunk(0, func([]Entry) bool { t.Error("unexpected call"); return true })
+}
+
+func TestStringMap_ExtremeByKey(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("mango", "1")
+	stringmap.Set("apple", "22")
+	stringmap.Set("zucchini", "3")
+	stringmap.Set("banana", "44")
+
+	minKey, maxKey, ok := stringmap.ExtremeByKey(func(a, b string) bool { return a < b })
+	if minKey != "apple" || maxKey != "zucchini" || !ok {
+		t.Errorf("expected apple, zucchini, true, got %s, %s, %t", minKey, maxKey, ok)
+	}
+
+	var empty StringMap
+	if _, _, ok := empty.ExtremeByKey(func(a, b string) bool { return a < b }); ok {
+		t.Errorf("expected not ok for empty map")
+	}
+}
+
+func TestStringMap_ExtremeByValue(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("mango", "1")
+	stringmap.Set("apple", "22")
+	stringmap.Set("zucchini", "3")
+	stringmap.Set("banana", "44")
+	stringmap.Set("cherry", "55")
+
+	// By length of the value; ties go to the first key
+	minKey, maxKey, ok := stringmap.ExtremeByValue(func(a, b string) bool { return len(a) < len(b) })
+	if minKey != "mango" || maxKey != "apple" || !ok {
+		t.Errorf("expected mango, apple, true, got %s, %s, %t", minKey, maxKey, ok)
+	}
+}
+
+func TestStringMap_ProjectValues(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("released", "2020-10-01")
+	stringmap.Set("updated", "2024-01-15")
+
+	years := stringmap.ProjectValues(func(value string) string {
+		return value[:4]
+	})
+
+	assertEntries(t, years, []struct{ k, v string }{
+		{"released", "2020"},
+		{"updated", "2024"},
+	})
+	if value, _ := stringmap.Value("updated"); value != "2024-01-15" {
+		t.Errorf("expected receiver to be unchanged, got %q", value)
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	var a, b StringMap
+	a.Set("apple", "a1")
+	a.Set("cherry", "a2")
+	a.Set("mango", "a3")
+	a.Set("zucchini", "a4")
+	b.Set("banana", "b1")
+	b.Set("cherry", "b2")
+	b.Set("peach", "b3")
+
+	merged := MergeSorted(a, b, func(aKey, bKey string) bool { return aKey < bKey })
+
+	assertEntries(t, merged, []struct{ k, v string }{
+		{"apple", "a1"},
+		{"banana", "b1"},
+		{"cherry", "b2"},
+		{"mango", "a3"},
+		{"peach", "b3"},
+		{"zucchini", "a4"},
+	})
+
+	// Merging with an empty map copies the other one
+	assertEntries(t, MergeSorted(StringMap{}, b, func(aKey, bKey string) bool { return aKey < bKey }), []struct{ k, v string }{
+		{"banana", "b1"},
+		{"cherry", "b2"},
+		{"peach", "b3"},
+	})
+}
+
+func TestStringMap_RankMap(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	ranks := stringmap.RankMap()
+	if len(ranks) != 3 || ranks["first"] != 0 || ranks["second"] != 1 || ranks["third"] != 2 {
+		t.Errorf("expected ranks first=0 second=1 third=2, got %v", ranks)
+	}
+
+	// Sort other keys to match the order of the map
+	keys := []string{"third", "first", "second"}
+	sort.Slice(keys, func(i, j int) bool { return ranks[keys[i]] < ranks[keys[j]] })
+	if fmt.Sprint(keys) != "[first second third]" {
+		t.Errorf("expected keys [first second third], got %v", keys)
+	}
+}
+
+func TestStringMap_SetAt(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	if err := stringmap.SetAt(1, "two"); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "two"},
+		{"third", "3"},
+	})
+
+	for _, index := range []int{-1, 3} {
+		if err := stringmap.SetAt(index, "x"); err == nil {
+			t.Errorf("expected error for index %d", index)
+		}
+	}
+
+	var empty StringMap
+	if err := empty.SetAt(0, "x"); err == nil {
+		t.Errorf("expected error for empty map")
+	}
+}
+
+func TestStringMap_RoundTripStable(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("", "empty key")
+	stringmap.Set("empty value", "")
+	stringmap.Set("unicode ✓", "  line separator")
+	stringmap.Set("escapes \"\\/", "<html> & \t\n")
+	stringmap.Set("invalid utf-8", "\xff")
+
+	stable, err := stringmap.RoundTripStable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Invalid UTF-8 is replaced by U+FFFD while marshaling
+	if stable {
+		t.Errorf("expected map with invalid UTF-8 not to be stable")
+	}
+
+	stringmap.Set("invalid utf-8", "�")
+	if stable, err := stringmap.RoundTripStable(); !stable || err != nil {
+		t.Errorf("expected map to be stable, got %t, %v", stable, err)
+	}
+
+	var empty StringMap
+	if stable, err := empty.RoundTripStable(); !stable || err != nil {
+		t.Errorf("expected empty map to be stable, got %t, %v", stable, err)
+	}
+}
+
+func TestStringMap_EntryHashes(t *testing.T) {
+	var before, after StringMap
+	before.Set("first", "1")
+	before.Set("second", "2")
+	before.Set("third", "3")
+	after.Set("first", "1")
+	after.Set("second", "two")
+	after.Set("third", "3")
+
+	beforeHashes, afterHashes := before.EntryHashes(), after.EntryHashes()
+	if len(beforeHashes) != 3 || len(afterHashes) != 3 {
+		t.Fatalf("expected 3 hashes each, got %d and %d", len(beforeHashes), len(afterHashes))
+	}
+	for i, changed := range []bool{false, true, false} {
+		if (beforeHashes[i] != afterHashes[i]) != changed {
+			t.Errorf("expected hash %d changed to be %t", i, changed)
+		}
+	}
+
+	// The separator keeps key and value apart
+	var a, b StringMap
+	a.Set("ab", "c")
+	b.Set("a", "bc")
+	if a.EntryHashes()[0] == b.EntryHashes()[0] {
+		t.Errorf("expected different hashes for different entries")
+	}
+}
+
+func TestStringMap_NormalizeLineEndings(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("windows", "a\r\nb\r\n")
+	stringmap.Set("mac", "a\rb")
+	stringmap.Set("mixed", "a\r\nb\nc\rd")
+
+	stringmap.NormalizeLineEndings("")
+	expected := []struct{ k, v string }{
+		{"windows", "a\nb\n"},
+		{"mac", "a\nb"},
+		{"mixed", "a\nb\nc\nd"},
+	}
+	assertEntries(t, stringmap, expected)
+
+	// Normalizing is idempotent
+	stringmap.NormalizeLineEndings("\n")
+	assertEntries(t, stringmap, expected)
+
+	stringmap.NormalizeLineEndings("\r\n")
+	stringmap.NormalizeLineEndings("\r\n")
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"windows", "a\r\nb\r\n"},
+		{"mac", "a\r\nb"},
+		{"mixed", "a\r\nb\r\nc\r\nd"},
+	})
+}
+
+func TestStringMap_BreakdownBy(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("b", "")
+	stringmap.Set("c", "3")
+	stringmap.Set("d", "")
+	stringmap.Set("e", "5")
+
+	breakdown := stringmap.BreakdownBy(func(key, value string) string {
+		if value == "" {
+			return "empty"
+		}
+		return "nonempty"
+	})
+
+	assertEntries(t, breakdown, []struct{ k, v string }{
+		{"nonempty", "3"},
+		{"empty", "2"},
+	})
+}
+
+func TestStringMap_ValueInt(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("port", "8080")
+	stringmap.Set("host", "localhost")
+
+	if value, ok, err := stringmap.ValueInt("port"); value != 8080 || !ok || err != nil {
+		t.Errorf("expected 8080, true, nil, got %d, %t, %v", value, ok, err)
+	}
+	if _, ok, err := stringmap.ValueInt("host"); !ok || err == nil {
+		t.Errorf("expected parse error for present key, got %t, %v", ok, err)
+	}
+	if _, ok, err := stringmap.ValueInt("notexist"); ok || err != nil {
+		t.Errorf("expected missing key without error, got %t, %v", ok, err)
+	}
+}
+
+func TestStringMap_ValueBool(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("debug", "true")
+	stringmap.Set("host", "localhost")
+
+	if value, ok, err := stringmap.ValueBool("debug"); !value || !ok || err != nil {
+		t.Errorf("expected true, true, nil, got %t, %t, %v", value, ok, err)
+	}
+	if _, ok, err := stringmap.ValueBool("host"); !ok || err == nil {
+		t.Errorf("expected parse error for present key, got %t, %v", ok, err)
+	}
+	if _, ok, err := stringmap.ValueBool("notexist"); ok || err != nil {
+		t.Errorf("expected missing key without error, got %t, %v", ok, err)
+	}
+}
+
+func TestStringMap_ValueFloat(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("ratio", "0.75")
+	stringmap.Set("host", "localhost")
+
+	if value, ok, err := stringmap.ValueFloat("ratio"); value != 0.75 || !ok || err != nil {
+		t.Errorf("expected 0.75, true, nil, got %f, %t, %v", value, ok, err)
+	}
+	if _, ok, err := stringmap.ValueFloat("host"); !ok || err == nil {
+		t.Errorf("expected parse error for present key, got %t, %v", ok, err)
+	}
+	if _, ok, err := stringmap.ValueFloat("notexist"); ok || err != nil {
+		t.Errorf("expected missing key without error, got %t, %v", ok, err)
+	}
+}
+
+func TestStringMap_EachRecover(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "two")
+	stringmap.Set("third", "3")
+	stringmap.Set("fourth", "four")
+
+	sum := 0
+	failed := stringmap.EachRecover(func(key, value string) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			panic(err)
+		}
+		sum += n
+	})
+
+	if fmt.Sprint(failed) != "[second fourth]" {
+		t.Errorf("expected failed keys [second fourth], got %v", failed)
+	}
+	if sum != 4 {
+		t.Errorf("expected all other entries to be processed, got sum %d", sum)
+	}
+
+	if failed := stringmap.EachRecover(func(key, value string) {}); failed != nil {
+		t.Errorf("expected no failed keys, got %v", failed)
+	}
+}
+
+func TestStringMap_PresenceMask(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("c", "3")
+	stringmap.Set("key64", "64")
+
+	if mask := stringmap.PresenceMask([]string{"a", "b", "c", "d"}); mask != 0x5 {
+		t.Errorf("expected mask %b, got %b", 0x5, mask)
+	}
+
+	// Keys beyond the 64th are ignored
+	keys := make([]string, 65)
+	keys[0] = "a"
+	keys[64] = "key64"
+	if mask := stringmap.PresenceMask(keys); mask != 1 {
+		t.Errorf("expected mask %b, got %b", 1, mask)
+	}
+}
+
+func TestStringMap_Presence(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("c", "3")
+
+	if present := stringmap.Presence([]string{"a", "b", "c"}); fmt.Sprint(present) != "[true false true]" {
+		t.Errorf("expected presence [true false true], got %v", present)
+	}
+}
+
+func TestStringMap_NewKeysSince(t *testing.T) {
+	var snapshot StringMap
+	snapshot.Set("first", "1")
+	snapshot.Set("second", "2")
+	snapshot.Set("third", "3")
+
+	var stringmap StringMap
+	stringmap.Set("new", "a")
+	stringmap.Set("third", "3")
+	stringmap.Set("first", "changed")
+	stringmap.Set("newer", "b")
+
+	if keys := stringmap.NewKeysSince(snapshot); fmt.Sprint(keys) != "[new newer]" {
+		t.Errorf("expected keys [new newer], got %v", keys)
+	}
+	if keys := snapshot.NewKeysSince(snapshot); keys == nil || len(keys) != 0 {
+		t.Errorf("expected empty slice, got %#v", keys)
+	}
+}
+
+func TestStringMap_WriteHTMLDefinitionList(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("name", "orderedmap")
+	stringmap.Set("<script>", `"alert('&')"`)
+
+	var buf bytes.Buffer
+	if err := stringmap.WriteHTMLDefinitionList(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<dl><dt>name</dt><dd>orderedmap</dd><dt>&lt;script&gt;</dt><dd>&#34;alert(&#39;&amp;&#39;)&#34;</dd></dl>`
+	if buf.String() != expected {
+		t.Errorf("expected html %s, got %s", expected, buf.String())
+	}
+
+	buf.Reset()
+	var empty StringMap
+	if err := empty.WriteHTMLDefinitionList(&buf); err != nil || buf.String() != "<dl></dl>" {
+		t.Errorf("expected html <dl></dl>, got %s, %v", buf.String(), err)
+	}
+}
+
+func TestStringMap_ReorderSteps(t *testing.T) {
+	var before, after StringMap
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		before.Set(key, key)
+	}
+	for _, key := range []string{"d", "a", "b", "e", "c"} {
+		after.Set(key, key)
+	}
+
+	moves, err := before.ReorderSteps(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(moves) != "[{3 0} {4 3}]" {
+		t.Errorf("expected moves [{3 0} {4 3}], got %v", moves)
+	}
+
+	// Replaying the moves results in the other order
+	order := before.Keys()
+	for _, move := range moves {
+		key := order[move.From]
+		order = append(order[:move.From], order[move.From+1:]...)
+		order = append(order[:move.To], append([]string{key}, order[move.To:]...)...)
+	}
+	if fmt.Sprint(order) != fmt.Sprint(after.Keys()) {
+		t.Errorf("expected replayed order %v, got %v", after.Keys(), order)
+	}
+
+	if moves, err := before.ReorderSteps(before); err != nil || len(moves) != 0 {
+		t.Errorf("expected no moves, got %v, %v", moves, err)
+	}
+}
+
+func TestStringMap_ReorderStepsErrors(t *testing.T) {
+	var stringmap, fewer, other StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("b", "2")
+	fewer.Set("a", "1")
+	other.Set("a", "1")
+	other.Set("c", "3")
+
+	if _, err := stringmap.ReorderSteps(fewer); err == nil {
+		t.Errorf("expected error for different number of keys")
+	}
+	if _, err := stringmap.ReorderSteps(other); err == nil {
+		t.Errorf("expected error for different keys")
+	}
+}
+
+func TestStringMap_ValidateValues(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("debug", "maybe")
+	stringmap.Set("host", "localhost")
+	stringmap.Set("port", "http")
+
+	isInt := func(value string) error {
+		_, err := strconv.Atoi(value)
+		return err
+	}
+	isBool := func(value string) error {
+		_, err := strconv.ParseBool(value)
+		return err
+	}
+
+	if err := stringmap.ValidateValues(map[string]func(string) error{"host": func(string) error { return nil }}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := stringmap.ValidateValues(map[string]func(string) error{
+		"port":    isInt,
+		"debug":   isBool,
+		"timeout": isInt,
+	})
+	failures, ok := err.(ValuesError)
+	if !ok {
+		t.Fatalf("expected ValuesError, got %#v", err)
+	}
+	if len(failures) != 2 || failures[0].Key != "debug" || failures[1].Key != "port" {
+		t.Errorf("expected failures for debug and port, got %v", failures)
+	}
+	expected := `invalid value for key "debug": strconv.ParseBool: parsing "maybe": invalid syntax; ` +
+		`invalid value for key "port": strconv.Atoi: parsing "http": invalid syntax`
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err)
+	}
+}
+
+func TestStringMap_EachMutable(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "")
+	stringmap.Set("third", "3")
+	stringmap.Set("fourth", "4")
+	stringmap.Set("fifth", "5")
+
+	stringmap.EachMutable(func(key, value string) (string, bool) {
+		if key == "third" {
+			// Removing a later key from within the callback is safe
+			stringmap.EachMutable(func(key, value string) (string, bool) {
+				return value, key != "fourth"
+			})
+		}
+		return value + "!", value != ""
+	})
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1!"},
+		{"third", "3!"},
+		{"fifth", "5!"},
+	})
+}
+
+func TestStringMap_Delete(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	stringmap.Delete("second")
+	stringmap.Delete("notexist")
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"third", "3"},
+	})
+	if _, ok := stringmap.Value("second"); ok {
+		t.Errorf("expected key %q to be deleted", "second")
+	}
+
+	// A deleted key is appended when set again
+	stringmap.Set("second", "two")
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"third", "3"},
+		{"second", "two"},
+	})
+
+	// Deleting from a zero value does not panic
+	var empty StringMap
+	empty.Delete("first")
+	if empty.Len() != 0 {
+		t.Errorf("expected empty map, got %d items", empty.Len())
+	}
+}
+
+func TestStringMap_IntersectKeysWith(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("host", "localhost")
+	stringmap.Set("user", "root")
+	stringmap.Set("port", "80")
+	stringmap.Set("debug", "true")
+
+	policyA := []string{"debug", "port", "host", "host"}
+	policyB := []string{"port", "user", "host"}
+
+	assertEntries(t, stringmap.IntersectKeysWith(policyA, policyB), []struct{ k, v string }{
+		{"host", "localhost"},
+		{"port", "80"},
+	})
+	assertEntries(t, stringmap.IntersectKeysWith(policyA, nil), nil)
+	assertEntries(t, stringmap.IntersectKeysWith(), []struct{ k, v string }{
+		{"host", "localhost"},
+		{"user", "root"},
+		{"port", "80"},
+		{"debug", "true"},
+	})
+}
+
+func TestStringMap_Has(t *testing.T) {
+	var stringmap StringMap
+	if stringmap.Has("first") {
+		t.Errorf("expected zero value not to have key %q", "first")
+	}
+
+	stringmap.Set("first", "")
+	stringmap.Set("second", "2")
+	if !stringmap.Has("first") || !stringmap.Has("second") {
+		t.Errorf("expected keys %q and %q to be present", "first", "second")
+	}
+	if stringmap.Has("third") {
+		t.Errorf("expected key %q not to be present", "third")
+	}
+}
+
+func TestStringMap_ContainsValue(t *testing.T) {
+	var stringmap StringMap
+	if stringmap.ContainsValue("") {
+		t.Errorf("expected zero value not to contain any value")
+	}
+
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	if !stringmap.ContainsValue("2") {
+		t.Errorf("expected value %q to be present", "2")
+	}
+	if stringmap.ContainsValue("first") {
+		t.Errorf("expected value %q not to be present", "first")
+	}
+}
+
+func TestStringMap_BulkLoad(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("old", "0")
+
+	err := stringmap.BulkLoad([]string{"first", "second", "third"}, map[string]string{
+		"third":  "3",
+		"first":  "1",
+		"second": "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+		{"third", "3"},
+	})
+}
+
+func TestStringMap_BulkLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		values map[string]string
+	}{
+		{"missing key", []string{"first"}, map[string]string{"first": "1", "second": "2"}},
+		{"missing value", []string{"first", "second"}, map[string]string{"first": "1", "third": "3"}},
+		{"duplicate key", []string{"first", "first"}, map[string]string{"first": "1", "second": "2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			stringmap.Set("old", "0")
+			if err := stringmap.BulkLoad(test.keys, test.values); err == nil {
+				t.Errorf("expected error")
+			}
+
+			// The map is left unchanged
+			assertEntries(t, stringmap, []struct{ k, v string }{{"old", "0"}})
+		})
+	}
+}
+
+func TestStringMap_Clear(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+
+	stringmap.Clear()
+	if stringmap.Len() != 0 || len(stringmap.Keys()) != 0 {
+		t.Errorf("expected empty map, got %d items", stringmap.Len())
+	}
+	if _, ok := stringmap.Value("first"); ok {
+		t.Errorf("expected key %q to be removed", "first")
+	}
+
+	// The map is usable after clearing, zero value included
+	stringmap.Set("third", "3")
+	assertEntries(t, stringmap, []struct{ k, v string }{{"third", "3"}})
+
+	var empty StringMap
+	empty.Clear()
+	empty.Set("first", "1")
+	assertEntries(t, empty, []struct{ k, v string }{{"first", "1"}})
+}
+
+// BenchmarkStringMap_Clear reuses a single map
+func BenchmarkStringMap_Clear(b *testing.B) {
+	b.ReportAllocs()
+
+	var stringmap StringMap
+	for i := 0; i < b.N; i++ {
+		stringmap.Clear()
+		for j := 0; j < 100; j++ {
+			stringmap.Set(benchmarkKeys[j], "value")
+		}
+	}
+}
+
+// BenchmarkStringMap_ClearNew allocates a new map on every reuse, for comparison
+func BenchmarkStringMap_ClearNew(b *testing.B) {
+	b.ReportAllocs()
+
+	var stringmap StringMap
+	for i := 0; i < b.N; i++ {
+		stringmap = StringMap{}
+		for j := 0; j < 100; j++ {
+			stringmap.Set(benchmarkKeys[j], "value")
+		}
+	}
+}
+
+func BenchmarkStringMap_Set(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var stringmap StringMap
+		for _, key := range benchmarkKeys {
+			stringmap.Set(key, "value")
+		}
+	}
+}
+
+func BenchmarkStringMap_SetNewStringMap(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		stringmap := NewStringMap(len(benchmarkKeys))
+		for _, key := range benchmarkKeys {
+			stringmap.Set(key, "value")
+		}
+	}
+}
+
+func BenchmarkStringMap_SetManyRepeated(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var stringmap StringMap
+		for _, key := range benchmarkKeys {
+			stringmap.SetMany(Pair{Key: key, Value: "value"})
+		}
+	}
+}
+
+func BenchmarkStringMap_GrowRepeated(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var stringmap StringMap
+		for _, key := range benchmarkKeys {
+			stringmap.Grow(1)
+			stringmap.Set(key, "value")
+		}
+	}
+}
+
+func BenchmarkStringMap_SetGrow(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var stringmap StringMap
+		stringmap.Set("first", "1")
+		stringmap.Grow(len(benchmarkKeys))
+		for _, key := range benchmarkKeys {
+			stringmap.Set(key, "value")
+		}
+	}
+}
+
+var benchmarkKeys = func() []string {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+func TestStringMap_RewriteKeys(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("old.host", "localhost")
+	stringmap.Set("debug", "true")
+	stringmap.Set("old.port", "80")
+
+	rewritten, err := stringmap.RewriteKeys(regexp.MustCompile(`^old\.(.*)$`), "new.$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, rewritten, []struct{ k, v string }{
+		{"new.host", "localhost"},
+		{"debug", "true"},
+		{"new.port", "80"},
+	})
+
+	_, err = stringmap.RewriteKeys(regexp.MustCompile(`^old\..*$`), "debug")
+	if expected := `keys "old.host" and "debug" both map to "debug"`; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestStringMap_Values(t *testing.T) {
+	var stringmap StringMap
+	if values := stringmap.Values(); values == nil || len(values) != 0 {
+		t.Errorf("expected empty slice, got %#v", values)
+	}
+
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "val2")
+	stringmap.Set("key2", "a third value")
+	stringmap.Set("key one", "value one")
+
+	values := stringmap.Values()
+	if fmt.Sprintf("%q", values) != `["value one" "val2" "a third value"]` {
+		t.Errorf("expected values in order, got %q", values)
+	}
+
+	// Manipulating the values does not affect the map
+	values[0] = "fu"
+	if value, _ := stringmap.Value("key one"); value != "value one" {
+		t.Errorf("expected value to be unchanged, got %q", value)
+	}
+}
+
+func TestStringMap_CacheKey(t *testing.T) {
+	build := func(pairs ...string) StringMap {
+		var stringmap StringMap
+		for i := 0; i < len(pairs); i += 2 {
+			stringmap.Set(pairs[i], pairs[i+1])
+		}
+		return stringmap
+	}
+
+	maps := []StringMap{
+		build(),
+		build("a", "1", "b", "2"),
+		build("b", "2", "a", "1"),
+		build("a", "1", "b", "3"),
+		build("a", "1b", "", "2"),
+		build("a", "1", "b2", ""),
+	}
+
+	seen := make(map[string]int)
+	for i, stringmap := range maps {
+		key := stringmap.CacheKey()
+		if j, exists := seen[key]; exists {
+			t.Errorf("expected maps %d and %d to have different cache keys, both got %s", j, i, key)
+		}
+		seen[key] = i
+	}
+
+	if build("a", "1", "b", "2").CacheKey() != maps[1].CacheKey() {
+		t.Errorf("expected equal maps to have the same cache key")
+	}
+}
+
+func TestStringMap_Pairs(t *testing.T) {
+	var stringmap StringMap
+	if pairs := stringmap.Pairs(); pairs == nil || len(pairs) != 0 {
+		t.Errorf("expected empty slice, got %#v", pairs)
+	}
+
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "val2")
+
+	pairs := stringmap.Pairs()
+	expected := []Pair{{"key one", "value 1"}, {"otherkey", "val2"}}
+	if len(pairs) != len(expected) || pairs[0] != expected[0] || pairs[1] != expected[1] {
+		t.Errorf("expected pairs %v, got %v", expected, pairs)
+	}
+
+	// Manipulating the pairs does not affect the map
+	pairs[0].Key = "fu"
+	pairs[0].Value = "bar"
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"key one", "value 1"},
+		{"otherkey", "val2"},
+	})
+}
+
+func TestStringMap_Clone(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Seal()
+
+	clone := stringmap.Clone()
+	if clone.Sealed() {
+		t.Errorf("expected clone not to be sealed")
+	}
+
+	clone.Set("first", "one")
+	clone.Delete("second")
+	clone.Set("third", "3")
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+	})
+	assertEntries(t, clone, []struct{ k, v string }{
+		{"first", "one"},
+		{"third", "3"},
+	})
+
+	// A clone of the zero value is usable
+	var empty StringMap
+	emptyClone := empty.Clone()
+	emptyClone.Set("first", "1")
+	if empty.Len() != 0 || emptyClone.Len() != 1 {
+		t.Errorf("expected clone of zero value to be independent, got %d and %d items", empty.Len(), emptyClone.Len())
+	}
+}
+
+func TestStringMap_Merge(t *testing.T) {
+	var stringmap, other StringMap
+	stringmap.Set("host", "localhost")
+	stringmap.Set("port", "80")
+	other.Set("user", "root")
+	other.Set("port", "8080")
+	other.Set("debug", "true")
+
+	stringmap.Merge(other)
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"host", "localhost"},
+		{"port", "8080"},
+		{"user", "root"},
+		{"debug", "true"},
+	})
+
+	// Merging an empty map changes nothing
+	stringmap.Merge(StringMap{})
+	if stringmap.Len() != 4 {
+		t.Errorf("expected 4 items, got %d", stringmap.Len())
+	}
+
+	// Merging into a zero value copies other
+	var empty StringMap
+	empty.Merge(other)
+	assertEntries(t, empty, []struct{ k, v string }{
+		{"user", "root"},
+		{"port", "8080"},
+		{"debug", "true"},
+	})
+}
+
+func TestStringMap_GetOrSet(t *testing.T) {
+	var stringmap StringMap
+	if value, existed := stringmap.GetOrSet("first", "1"); value != "1" || existed {
+		t.Errorf("expected %q, false, got %q, %t", "1", value, existed)
+	}
+	stringmap.Set("second", "2")
+	if value, existed := stringmap.GetOrSet("first", "one"); value != "1" || !existed {
+		t.Errorf("expected %q, true, got %q, %t", "1", value, existed)
+	}
+	if value, existed := stringmap.GetOrSet("third", "3"); value != "3" || existed {
+		t.Errorf("expected %q, false, got %q, %t", "3", value, existed)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+		{"third", "3"},
+	})
+}
+
+func TestStringMap_Pop(t *testing.T) {
+	var stringmap StringMap
+	if value, ok := stringmap.Pop("first"); value != "" || ok {
+		t.Errorf("expected empty value and false on zero value, got %q, %t", value, ok)
+	}
+
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	if value, ok := stringmap.Pop("second"); value != "2" || !ok {
+		t.Errorf("expected %q, true, got %q, %t", "2", value, ok)
+	}
+	if value, ok := stringmap.Pop("second"); value != "" || ok {
+		t.Errorf("expected empty value and false, got %q, %t", value, ok)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"third", "3"},
+	})
+}
+
+func TestStringMap_PopFirstLast(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+	stringmap.Set("fourth", "4")
+
+	if key, value, ok := stringmap.PopFirst(); key != "first" || value != "1" || !ok {
+		t.Errorf("expected first, 1, true, got %s, %s, %t", key, value, ok)
+	}
+	if key, value, ok := stringmap.PopLast(); key != "fourth" || value != "4" || !ok {
+		t.Errorf("expected fourth, 4, true, got %s, %s, %t", key, value, ok)
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"second", "2"},
+		{"third", "3"},
+	})
+
+	stringmap.PopFirst()
+	stringmap.PopLast()
+	if _, _, ok := stringmap.PopFirst(); ok {
+		t.Errorf("expected PopFirst on empty map not to be ok")
+	}
+	if _, _, ok := stringmap.PopLast(); ok {
+		t.Errorf("expected PopLast on empty map not to be ok")
+	}
+	if stringmap.Has("second") || stringmap.Has("third") {
+		t.Errorf("expected popped keys to be removed")
+	}
+}
+
+func TestStringMap_FirstLast(t *testing.T) {
+	var stringmap StringMap
+	if _, _, ok := stringmap.First(); ok {
+		t.Errorf("expected First on empty map not to be ok")
+	}
+	if _, _, ok := stringmap.Last(); ok {
+		t.Errorf("expected Last on empty map not to be ok")
+	}
+
+	stringmap.Set("first", "1")
+	if key, value, ok := stringmap.Last(); key != "first" || value != "1" || !ok {
+		t.Errorf("expected first, 1, true, got %s, %s, %t", key, value, ok)
+	}
+
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+	if key, value, ok := stringmap.First(); key != "first" || value != "1" || !ok {
+		t.Errorf("expected first, 1, true, got %s, %s, %t", key, value, ok)
+	}
+	if key, value, ok := stringmap.Last(); key != "third" || value != "3" || !ok {
+		t.Errorf("expected third, 3, true, got %s, %s, %t", key, value, ok)
+	}
+	if stringmap.Len() != 3 {
+		t.Errorf("expected 3 items, got %d", stringmap.Len())
+	}
+}
+
+func TestStringMap_KeyAtValueAt(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	tests := []struct {
+		i     int
+		key   string
+		value string
+		ok    bool
+	}{
+		{0, "first", "1", true},
+		{2, "third", "3", true},
+		{3, "", "", false},
+		{-1, "third", "3", true},
+		{-3, "first", "1", true},
+		{-4, "", "", false},
+	}
+	for _, test := range tests {
+		if key, ok := stringmap.KeyAt(test.i); key != test.key || ok != test.ok {
+			t.Errorf("expected key at %d to be %q, %t, got %q, %t", test.i, test.key, test.ok, key, ok)
+		}
+		if value, ok := stringmap.ValueAt(test.i); value != test.value || ok != test.ok {
+			t.Errorf("expected value at %d to be %q, %t, got %q, %t", test.i, test.value, test.ok, value, ok)
+		}
+	}
+
+	var empty StringMap
+	if _, ok := empty.KeyAt(0); ok {
+		t.Errorf("expected no key in empty map")
+	}
+}
+
+func TestStringMap_IndexOf(t *testing.T) {
+	var stringmap StringMap
+	if i := stringmap.IndexOf("first"); i != -1 {
+		t.Errorf("expected -1 on zero value, got %d", i)
+	}
+
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+	stringmap.Delete("first")
+
+	tests := []struct {
+		key   string
+		index int
+	}{
+		{"second", 0},
+		{"third", 1},
+		{"first", -1},
+	}
+	for _, test := range tests {
+		if i := stringmap.IndexOf(test.key); i != test.index {
+			t.Errorf("expected index of %q to be %d, got %d", test.key, test.index, i)
+		}
+	}
+}
+
+func TestStringMap_InsertAt(t *testing.T) {
+	var stringmap StringMap
+	if err := stringmap.InsertAt(0, "second", "2"); err != nil {
+		t.Fatal(err)
+	}
+	stringmap.Set("fourth", "4")
+
+	// Insert new keys at the front, in between and at the end
+	for _, insert := range []struct {
+		i    int
+		k, v string
+	}{{0, "first", "1"}, {2, "third", "3"}, {4, "fifth", "5"}} {
+		if err := stringmap.InsertAt(insert.i, insert.k, insert.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+		{"third", "3"},
+		{"fourth", "4"},
+		{"fifth", "5"},
+	})
+
+	// Existing keys move to the new position
+	if err := stringmap.InsertAt(1, "fourth", "four"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringmap.InsertAt(4, "first", "one"); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"fourth", "four"},
+		{"second", "2"},
+		{"third", "3"},
+		{"fifth", "5"},
+		{"first", "one"},
+	})
+}
+
+func TestStringMap_InsertAtErrors(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+
+	tests := []struct {
+		name string
+		i    int
+		key  string
+	}{
+		{"negative index", -1, "third"},
+		{"beyond end", 3, "third"},
+		{"existing key at end", 2, "first"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := stringmap.InsertAt(test.i, test.key, "x"); err == nil {
+				t.Errorf("expected error")
+			}
+			assertEntries(t, stringmap, []struct{ k, v string }{
+				{"first", "1"},
+				{"second", "2"},
+			})
+		})
+	}
+}
+
+func TestStringMap_MoveToFrontBack(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+	stringmap.Set("fourth", "4")
+
+	if !stringmap.MoveToFront("third") {
+		t.Errorf("expected key %q to be moved", "third")
+	}
+	if !stringmap.MoveToBack("first") {
+		t.Errorf("expected key %q to be moved", "first")
+	}
+	if stringmap.MoveToFront("notexist") || stringmap.MoveToBack("notexist") {
+		t.Errorf("expected missing key not to be moved")
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"third", "3"},
+		{"second", "2"},
+		{"fourth", "4"},
+		{"first", "1"},
+	})
+}
+
+func TestStringMap_MoveBeforeAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		move     func(m *StringMap) bool
+		expected string
+	}{
+		{"before later", func(m *StringMap) bool { return m.MoveBefore("a", "d") }, "[b c a d e]"},
+		{"before earlier", func(m *StringMap) bool { return m.MoveBefore("d", "b") }, "[a d b c e]"},
+		{"before first", func(m *StringMap) bool { return m.MoveBefore("e", "a") }, "[e a b c d]"},
+		{"before itself", func(m *StringMap) bool { return m.MoveBefore("c", "c") }, "[a b c d e]"},
+		{"after later", func(m *StringMap) bool { return m.MoveAfter("a", "d") }, "[b c d a e]"},
+		{"after earlier", func(m *StringMap) bool { return m.MoveAfter("d", "a") }, "[a d b c e]"},
+		{"after last", func(m *StringMap) bool { return m.MoveAfter("a", "e") }, "[b c d e a]"},
+		{"after itself", func(m *StringMap) bool { return m.MoveAfter("c", "c") }, "[a b c d e]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				stringmap.Set(key, key)
+			}
+
+			if !test.move(&stringmap) {
+				t.Errorf("expected move to succeed")
+			}
+			if keys := fmt.Sprint(stringmap.Keys()); keys != test.expected {
+				t.Errorf("expected keys %s, got %s", test.expected, keys)
+			}
+			if value, _ := stringmap.Value("a"); value != "a" || stringmap.Len() != 5 {
+				t.Errorf("expected entries to be unchanged")
+			}
+		})
+	}
+}
+
+func TestStringMap_MoveBeforeAfterMissing(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.Set("b", "2")
+
+	if stringmap.MoveBefore("a", "notexist") || stringmap.MoveBefore("notexist", "a") {
+		t.Errorf("expected MoveBefore with missing key to fail")
+	}
+	if stringmap.MoveAfter("a", "notexist") || stringmap.MoveAfter("notexist", "a") {
+		t.Errorf("expected MoveAfter with missing key to fail")
+	}
+	if keys := fmt.Sprint(stringmap.Keys()); keys != "[a b]" {
+		t.Errorf("expected keys [a b], got %s", keys)
+	}
+}
+
+func TestStringMap_Reverse(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("b", "1")
+	stringmap.Set("c", "3")
+	stringmap.Set("a", "2")
+
+	stringmap.Reverse()
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"a", "2"},
+		{"c", "3"},
+		{"b", "1"},
+	})
+
+	var empty StringMap
+	empty.Reverse()
+	if empty.Len() != 0 {
+		t.Errorf("expected empty map, got %d items", empty.Len())
+	}
+}
+
+func TestStringMap_MapValues(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", " one ")
+	stringmap.Set("second", "two")
+
+	mapped := stringmap.MapValues(func(key, value string) string {
+		return key + "=" + strings.TrimSpace(value)
+	})
+
+	assertEntries(t, mapped, []struct{ k, v string }{
+		{"first", "first=one"},
+		{"second", "second=two"},
+	})
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", " one "},
+		{"second", "two"},
+	})
+}
+
+func TestStringMap_Apply(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", " one ")
+	stringmap.Set("second", "two")
+
+	stringmap.Apply(func(key, value string) string {
+		return strings.ToUpper(strings.TrimSpace(value))
+	})
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "ONE"},
+		{"second", "TWO"},
+	})
+}
+
+func TestStringMap_Equal(t *testing.T) {
+	build := func(pairs ...string) StringMap {
+		var stringmap StringMap
+		for i := 0; i < len(pairs); i += 2 {
+			stringmap.Set(pairs[i], pairs[i+1])
+		}
+		return stringmap
+	}
+
+	tests := []struct {
+		name      string
+		a, b      StringMap
+		equal     bool
+		unordered bool
+	}{
+		{"zero values", StringMap{}, StringMap{}, true, true},
+		{"same", build("a", "1", "b", "2"), build("a", "1", "b", "2"), true, true},
+		{"other order", build("a", "1", "b", "2"), build("b", "2", "a", "1"), false, true},
+		{"other value", build("a", "1", "b", "2"), build("a", "1", "b", "3"), false, false},
+		{"other key", build("a", "1", "b", "2"), build("a", "1", "c", "2"), false, false},
+		{"fewer keys", build("a", "1", "b", "2"), build("a", "1"), false, false},
+		{"empty", StringMap{}, build("a", "1"), false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.a.Equal(test.b) != test.equal || test.b.Equal(test.a) != test.equal {
+				t.Errorf("expected Equal to be %t", test.equal)
+			}
+			if test.a.EqualUnordered(test.b) != test.unordered || test.b.EqualUnordered(test.a) != test.unordered {
+				t.Errorf("expected EqualUnordered to be %t", test.unordered)
+			}
+		})
+	}
+}
+
+func TestStringMap_SortStable(t *testing.T) {
+	var stringmap StringMap
+	for i, value := range []string{"bb", "a", "cc", "d", "eee", "ff", "g"} {
+		stringmap.Set("key"+strconv.Itoa(i), value)
+	}
+
+	// Sort by the length of the value
+	stringmap.SortStable(func(s, t string) bool {
+		return len(s) < len(t)
+	})
+
+	if values := fmt.Sprint(stringmap.Values()); values != "[a d g bb cc ff eee]" {
+		t.Errorf("expected values [a d g bb cc ff eee], got %s", values)
+	}
+}
+
+func TestStringMap_SortKeysStable(t *testing.T) {
+	var stringmap StringMap
+	for i, key := range []string{"bb", "a", "cc", "d", "eee", "ff", "g"} {
+		stringmap.Set(key, strconv.Itoa(i))
+	}
+
+	// Sort by the length of the key
+	stringmap.SortKeysStable(func(s, t string) bool {
+		return len(s) < len(t)
+	})
+
+	if keys := fmt.Sprint(stringmap.Keys()); keys != "[a d g bb cc ff eee]" {
+		t.Errorf("expected keys [a d g bb cc ff eee], got %s", keys)
+	}
+	if value, _ := stringmap.Value("eee"); value != "4" {
+		t.Errorf("expected value for key %q to be %q, got %q", "eee", "4", value)
+	}
+}
+
+func TestStringMap_String(t *testing.T) {
+	var stringmap StringMap
+	if s := fmt.Sprint(stringmap); s != "StringMap{}" {
+		t.Errorf("expected StringMap{}, got %s", s)
+	}
+
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "two words")
+	if expected, s := "StringMap{first:1, second:two words}", fmt.Sprint(stringmap); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+	if expected, s := `orderedmap.StringMap{"first":"1", "second":"two words"}`, fmt.Sprintf("%#v", stringmap); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func TestNewStringMap(t *testing.T) {
+	stringmap := NewStringMap(2)
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "1")
+	stringmap.Set("third", "3")
+
+	assertEntries(t, *stringmap, []struct{ k, v string }{
+		{"second", "2"},
+		{"first", "1"},
+		{"third", "3"},
+	})
+}
+
+func TestFromMap(t *testing.T) {
+	src := map[string]string{"a": "1", "b": "2", "c": "3"}
+
+	stringmap, err := FromMap(src, []string{"c", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"c", "3"},
+		{"a", "1"},
+		{"b", "2"},
+	})
+
+	tests := []struct {
+		name     string
+		keyOrder []string
+		err      string
+	}{
+		{"unknown key", []string{"c", "a", "b", "d"}, `key "d" not in map`},
+		{"duplicate key", []string{"c", "a", "c", "b"}, `duplicate key "c" in order`},
+		{"missing keys", []string{"b"}, `keys ["a" "c"] missing from order`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := FromMap(src, test.keyOrder); err == nil || err.Error() != test.err {
+				t.Errorf("expected error %s, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestFromSortedMap(t *testing.T) {
+	stringmap := FromSortedMap(map[string]string{"b": "2", "c": "3", "a": "1"})
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"a", "1"},
+		{"b", "2"},
+		{"c", "3"},
+	})
+}
+
+func TestFromPairs(t *testing.T) {
+	stringmap := FromPairs(
+		Pair{Key: "b", Value: "2"},
+		Pair{Key: "a", Value: "1"},
+		Pair{Key: "b", Value: "3"},
+	)
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"b", "3"},
+		{"a", "1"},
+	})
+
+	assertEntries(t, FromPairs(), []struct{ k, v string }{})
+}
+
+func TestStringMap_SetMany(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("a", "1")
+	stringmap.SetMany(
+		Pair{Key: "b", Value: "2"},
+		Pair{Key: "a", Value: "3"},
+		Pair{Key: "c", Value: "4"},
+		Pair{Key: "b", Value: "5"},
+	)
+	stringmap.SetMany()
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"a", "3"},
+		{"b", "5"},
+		{"c", "4"},
+	})
+}
+
+func TestStringMap_MarshalIndent(t *testing.T) {
+	var stringmap StringMap
+	b, err := stringmap.MarshalIndent("", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "{}" {
+		t.Errorf("expected {}, got %s", b)
+	}
+
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "1")
+	b, err = stringmap.MarshalIndent("> ", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n> \t\"second\": \"2\",\n> \t\"first\": \"1\"\n> }"
+	if string(b) != expected {
+		t.Errorf("expected %q, got %q", expected, b)
+	}
+
+	// Same as a struct with the same fields
+	b2, _ := json.MarshalIndent(struct {
+		Second string `json:"second"`
+		First  string `json:"first"`
+	}{"2", "1"}, "> ", "\t")
+	if string(b) != string(b2) {
+		t.Errorf("expected %q, got %q", b2, b)
+	}
+}
+
+func TestStringMap_MarshalJSONUnescaped(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("url", "https://example.com/?a=1&b=<2>")
+	stringmap.Set("<tag>", "\"quoted\"\n")
+
+	b, err := stringmap.MarshalJSONUnescaped()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"url":"https://example.com/?a=1&b=<2>","<tag>":"\"quoted\"\n"}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	// MarshalJSON keeps escaping
+	b, _ = stringmap.MarshalJSON()
+	expected = `{"url":"https://example.com/?a=1\u0026b=\u003c2\u003e","\u003ctag\u003e":"\"quoted\"\n"}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestStringMap_Encode(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "<1>")
+
+	var buf bytes.Buffer
+	if err := stringmap.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := stringmap.MarshalJSON()
+	if buf.String() != string(expected) {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+
+	for n := 0; n < len(expected); n++ {
+		w := &limitedWriter{n: n}
+		if err := stringmap.Encode(w); err != errWriteLimit {
+			t.Errorf("expected write error for limit %d, got %v", n, err)
+		}
+		if w.written > n {
+			t.Errorf("expected writing to stop at the limit of %d bytes, wrote %d", n, w.written)
+		}
+	}
+}
+
+var errWriteLimit = errors.New("write limit reached")
+
+// limitedWriter accepts only writes that fit in the remaining n bytes
+type limitedWriter struct {
+	n       int
+	written int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n-w.written {
+		return 0, errWriteLimit
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestStringMap_Decode(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("existing", "0")
+
+	r := strings.NewReader(`{"second":"2","first":"1","second":"3"}`)
+	if err := stringmap.Decode(r); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"existing", "0"},
+		{"second", "3"},
+		{"first", "1"},
+	})
+
+	for _, input := range []string{`["a"]`, `{"a":"1"`, `{"a":"1"} {}`, `{"a":1}`, ``} {
+		var stringmap StringMap
+		if err := stringmap.Decode(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for input %q", input)
+		}
+	}
+}
+
+func TestStringMap_UnmarshalJSONStrict(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("existing", "0")
+
+	if err := stringmap.UnmarshalJSONStrict([]byte(`{"existing":"1","new":"2"}`)); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"existing", "1"},
+		{"new", "2"},
+	})
+
+	err := stringmap.UnmarshalJSONStrict([]byte(`{"a":"1","b":"2","a":"3"}`))
+	if expected := `duplicate key "a"`; err == nil || err.Error() != expected {
+		t.Errorf("expected error %s, got %v", expected, err)
+	}
+}
+
+func TestStringMap_UnmarshalJSONWithPolicy(t *testing.T) {
+	input := []byte(`{"a":"1","b":"2","a":"3","c":"4","a":"5"}`)
+	tests := []struct {
+		name     string
+		policy   DuplicateKeyPolicy
+		expected []struct{ k, v string }
+	}{
+		{"OverwriteDuplicates", OverwriteDuplicates, []struct{ k, v string }{{"a", "5"}, {"b", "2"}, {"c", "4"}}},
+		{"KeepFirst", KeepFirst, []struct{ k, v string }{{"a", "1"}, {"b", "2"}, {"c", "4"}}},
+		{"KeepLast", KeepLast, []struct{ k, v string }{{"b", "2"}, {"c", "4"}, {"a", "5"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			if err := stringmap.UnmarshalJSONWithPolicy(input, test.policy); err != nil {
+				t.Fatal(err)
+			}
+			assertEntries(t, stringmap, test.expected)
+		})
+	}
+
+	var stringmap StringMap
+	if err := stringmap.UnmarshalJSONWithPolicy(input, RejectDuplicates); err == nil {
+		t.Errorf("expected error for RejectDuplicates")
+	}
+	if err := stringmap.UnmarshalJSONWithPolicy(input, DuplicateKeyPolicy(42)); err == nil {
+		t.Errorf("expected error for invalid policy")
+	}
+}
+
+func TestStringMap_DecodeWithLimits(t *testing.T) {
+	input := `{"a":"1","bb":"22","a":"333"}`
+	tests := []struct {
+		name   string
+		limits DecodeLimits
+		err    string
+	}{
+		{"no limits", DecodeLimits{}, ""},
+		{"within limits", DecodeLimits{MaxEntries: 3, MaxKeyLen: 2, MaxValueLen: 3}, ""},
+		{"too many entries", DecodeLimits{MaxEntries: 2}, "more than 2 entries"},
+		{"key too long", DecodeLimits{MaxKeyLen: 1}, "key of 2 bytes exceeds maximum length of 1"},
+		{"value too long", DecodeLimits{MaxValueLen: 2}, `value of key "a" exceeds maximum length of 2`},
+		{"exactly max bytes", DecodeLimits{MaxBytes: int64(len(input))}, ""},
+		{"too many bytes", DecodeLimits{MaxBytes: int64(len(input)) - 1}, fmt.Sprintf("input exceeds %d bytes", len(input)-1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			err := stringmap.DecodeWithLimits(strings.NewReader(input), test.limits)
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertEntries(t, stringmap, []struct{ k, v string }{{"a", "333"}, {"bb", "22"}})
+			} else if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %s, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestStringMap_DecodeWithLimitsMaxBytes(t *testing.T) {
+	// A huge value is not read beyond the limit
+	r := &countingReader{r: io.MultiReader(
+		strings.NewReader(`{"a":"`),
+		strings.NewReader(strings.Repeat("x", 1<<20)),
+		strings.NewReader(`"}`),
+	)}
+
+	var stringmap StringMap
+	if err := stringmap.DecodeWithLimits(r, DecodeLimits{MaxBytes: 1024}); err == nil {
+		t.Fatal("expected error")
+	}
+	if r.n > 1024+1 {
+		t.Errorf("expected at most 1025 bytes to be read, read %d", r.n)
+	}
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestStringMap_Rename(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	if err := stringmap.Rename("second", "Second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringmap.Rename("third", "third"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stringmap.Rename("missing", "fourth"); err == nil || err.Error() != `key "missing" is missing` {
+		t.Errorf("expected error for missing key, got %v", err)
+	}
+	if err := stringmap.Rename("first", "third"); err == nil || err.Error() != `key "third" already exists` {
+		t.Errorf("expected error for existing key, got %v", err)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"Second", "2"},
+		{"third", "3"},
+	})
+}
+
+func TestStringMap_UpdateExisting(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+
+	if !stringmap.UpdateExisting("first", func(old string) string { return old + ",one" }) {
+		t.Errorf("expected existing key to be updated")
+	}
+	if stringmap.UpdateExisting("third", func(old string) string {
+		t.Errorf("expected fn not to be called for missing key")
+		return old
+	}) {
+		t.Errorf("expected missing key not to be updated")
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1,one"},
+		{"second", "2"},
+	})
+}
+
+func TestStringMap_ForEach(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("first", "1")
+	stringmap.Set("second", "2")
+	stringmap.Set("third", "3")
+
+	var visited []string
+	stringmap.ForEach(func(key, value string) bool {
+		visited = append(visited, key+"="+value)
+		return key != "second"
+	})
+	if s := fmt.Sprint(visited); s != "[first=1 second=2]" {
+		t.Errorf("expected [first=1 second=2], got %s", s)
+	}
+}
+
+func TestStringMap_Grow(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Grow(0)
+	stringmap.Grow(-1)
+	stringmap.Set("first", "1")
+	stringmap.Grow(10)
+
+	allocs := testing.AllocsPerRun(1, func() {
+		stringmap := stringmap.Clone()
+		stringmap.Grow(len(benchmarkKeys))
+		for _, key := range benchmarkKeys {
+			stringmap.Set(key, "value")
+		}
+	})
+	if unhinted := testing.AllocsPerRun(1, func() {
+		stringmap := stringmap.Clone()
+		for _, key := range benchmarkKeys {
+			stringmap.Set(key, "value")
+		}
+	}); allocs >= unhinted {
+		t.Errorf("expected fewer than %.0f allocations after Grow, got %.0f", unhinted, allocs)
+	}
+
+	stringmap.Set("second", "2")
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"first", "1"},
+		{"second", "2"},
+	})
+}
+
+func TestStringMap_GrowRepeated(t *testing.T) {
+	var stringmap StringMap
+	allocs := testing.AllocsPerRun(1, func() {
+		stringmap = StringMap{}
+		for _, key := range benchmarkKeys {
+			stringmap.Grow(1)
+			stringmap.Set(key, "value")
+		}
+	})
+
+	// Growing geometrically reallocates a logarithmic number of times, rather than on every call
+	if allocs > 1000 {
+		t.Errorf("expected amortized growth, got %.0f allocations for %d calls", allocs, len(benchmarkKeys))
+	}
+	if stringmap.Len() != len(benchmarkKeys) {
+		t.Errorf("expected %d entries, got %d", len(benchmarkKeys), stringmap.Len())
+	}
+}
+
+func TestStringMap_Compact(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Compact()
+
+	for _, key := range benchmarkKeys {
+		stringmap.Set(key, "value")
+	}
+	for _, key := range benchmarkKeys[:len(benchmarkKeys)-2] {
+		stringmap.Delete(key)
+	}
+	stringmap.Compact()
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"key9998", "value"},
+		{"key9999", "value"},
+	})
+
+	// The compacted map is still usable
+	stringmap.Set("key0", "first")
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"key9998", "value"},
+		{"key9999", "value"},
+		{"key0", "first"},
+	})
+}
+
+func TestStringMap_SetAlgebra(t *testing.T) {
+	var a, b StringMap
+	a.Set("first", "a1")
+	a.Set("second", "a2")
+	a.Set("third", "a3")
+	b.Set("fourth", "b4")
+	b.Set("third", "b3")
+	b.Set("first", "b1")
+
+	assertEntries(t, a.Intersect(b), []struct{ k, v string }{
+		{"first", "a1"},
+		{"third", "a3"},
+	})
+	assertEntries(t, a.Union(b), []struct{ k, v string }{
+		{"first", "b1"},
+		{"second", "a2"},
+		{"third", "b3"},
+		{"fourth", "b4"},
+	})
+	assertEntries(t, a.Difference(b), []struct{ k, v string }{
+		{"second", "a2"},
+	})
+
+	// Inputs are untouched
+	assertEntries(t, a, []struct{ k, v string }{
+		{"first", "a1"},
+		{"second", "a2"},
+		{"third", "a3"},
+	})
+	assertEntries(t, b, []struct{ k, v string }{
+		{"fourth", "b4"},
+		{"third", "b3"},
+		{"first", "b1"},
+	})
+}
+
+func TestStringMap_KeysWithPrefix(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("db.host", "localhost")
+	stringmap.Set("http.port", "80")
+	stringmap.Set("db.port", "5432")
+
+	if keys := fmt.Sprint(stringmap.KeysWithPrefix("db.")); keys != "[db.host db.port]" {
+		t.Errorf("expected keys [db.host db.port], got %s", keys)
+	}
+	if keys := stringmap.KeysWithPrefix("smtp."); keys == nil || len(keys) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", keys)
+	}
+
+	keys := stringmap.KeysFunc(func(key string) bool { return strings.HasSuffix(key, ".port") })
+	if s := fmt.Sprint(keys); s != "[http.port db.port]" {
+		t.Errorf("expected keys [http.port db.port], got %s", s)
+	}
+
+	// Modifying the result leaves the map unchanged
+	keys[0] = "changed"
+	if !stringmap.Has("http.port") || stringmap.Has("changed") {
+		t.Errorf("expected map to be unaffected by modifying returned keys")
+	}
+}
+
+func TestStringMap_SetManyRepeated(t *testing.T) {
+	var stringmap StringMap
+	allocs := testing.AllocsPerRun(1, func() {
+		stringmap = StringMap{}
+		for _, key := range benchmarkKeys {
+			stringmap.SetMany(Pair{Key: key, Value: "value"})
+		}
+	})
+
+	// Each call must not copy the storage grown by the previous ones
+	if allocs > 1000 {
+		t.Errorf("expected amortized growth, got %.0f allocations for %d calls", allocs, len(benchmarkKeys))
+	}
+	if stringmap.Len() != len(benchmarkKeys) {
+		t.Errorf("expected %d entries, got %d", len(benchmarkKeys), stringmap.Len())
+	}
+}
+
+// assertEntries asserts stringmap holds exactly the expected entries in order
+func assertEntries(t *testing.T, stringmap StringMap, expected []struct{ k, v string }) {
+	t.Helper()
+
+	if stringmap.Len() != len(expected) {
+		t.Fatalf("expected %d items, got %d; %#v", len(expected), stringmap.Len(), stringmap.Keys())
+	}
+	for i, key := range stringmap.Keys() {
+		if key != expected[i].k {
+			t.Errorf("expected item %d to have key %q, got %q", i, expected[i].k, key)
+		}
+		if value, _ := stringmap.Value(key); value != expected[i].v {
+			t.Errorf("expected item %d to have value %q, got %q", i, expected[i].v, value)
+		}
+	}
+}
+
 func ExampleStringMap_MarshalJSON() {
 	var m StringMap
 	m.Set("first", "1")
@@ -307,7 +2825,7 @@ func ExampleStringMap_UnmarshalJSON() {
 	// third = 3
 }
 
-func ExampleStringMap_Sortable() {
+func ExampleStringMap_sortable() {
 	var m StringMap
 	m.Set("first", "1")
 	m.Set("second", "2")
@@ -0,0 +1,53 @@
+package orderedmap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// MarshalText implements encoding.TextMarshaler
+// Each entry is written in order as a line key=value terminated by a newline. Keys and values are
+// written verbatim, so a key may not contain '=' and neither may contain a line break
+func (m StringMap) MarshalText() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, key := range m.keys {
+		value := m.values[key]
+		if strings.ContainsAny(key, "=\r\n") {
+			return nil, fmt.Errorf("key %q cannot be encoded as text", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("value of key %q cannot be encoded as text", key)
+		}
+
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+// Every line key=value is split on its first '='; whitespace around keys and values is kept, lines
+// consisting of only whitespace are skipped and line endings may be \n or \r\n.
+// The contents of the map are replaced by the decoded entries; on error the map is left unchanged
+func (m *StringMap) UnmarshalText(text []byte) error {
+	var decoded StringMap
+	for i, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: missing '='", i+1)
+		}
+		decoded.Set(line[:eq], line[eq+1:])
+	}
+
+	m.ReplaceAll(decoded.Pairs())
+	return nil
+}
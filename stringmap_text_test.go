@@ -0,0 +1,66 @@
+package orderedmap_test
+
+import (
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestStringMap_MarshalText(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "a=b")
+	stringmap.Set(" padded ", "")
+
+	b, err := stringmap.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "second=2\nfirst=a=b\n padded =\n"; string(b) != expected {
+		t.Errorf("expected %q, got %q", expected, b)
+	}
+}
+
+func TestStringMap_MarshalTextErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		key, value string
+	}{
+		{"key with =", "a=b", "c"},
+		{"key with newline", "a\nb", "c"},
+		{"value with newline", "a", "b\nc"},
+		{"value with carriage return", "a", "b\r"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			stringmap.Set(test.key, test.value)
+			if _, err := stringmap.MarshalText(); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestStringMap_UnmarshalText(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("stale", "entry")
+
+	input := "second=2\r\n\n  \nfirst=a=b\n padded =\nsecond=4"
+	if err := stringmap.UnmarshalText([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"second", "4"},
+		{"first", "a=b"},
+		{" padded ", ""},
+	})
+
+	if err := stringmap.UnmarshalText([]byte("a=1\nno equals sign\n")); err == nil {
+		t.Errorf("expected error for line without '='")
+	}
+	if stringmap.Len() != 3 {
+		t.Errorf("expected map to be left unchanged on error")
+	}
+}
@@ -0,0 +1,93 @@
+package orderedmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// xmlEntry is the name of the element each entry is encoded as
+const xmlEntry = "entry"
+
+// MarshalXML implements xml.Marshaler
+// Each entry is encoded in order as a child element <entry key="key">value</entry>
+func (m StringMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, key := range m.keys {
+		entry := xml.StartElement{
+			Name: xml.Name{Local: xmlEntry},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+		}
+		if err := e.EncodeElement(m.values[key], entry); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML implements xml.Unmarshaler
+// It reads the <entry key="key">value</entry> child elements in document order, setting each entry
+func (m *StringMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if token.Name.Local != xmlEntry {
+				return fmt.Errorf("unexpected element <%s> in <%s>", token.Name.Local, start.Name.Local)
+			}
+			key, ok := xmlKey(token)
+			if !ok {
+				return fmt.Errorf("missing key attribute on <%s>", xmlEntry)
+			}
+			value, err := xmlValue(d)
+			if err != nil {
+				return err
+			}
+			m.Set(key, value)
+		case xml.CharData:
+			if len(bytes.TrimSpace(token)) > 0 {
+				return fmt.Errorf("unexpected text %q in <%s>", token, start.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// xmlKey returns the value of the key attribute of an entry element
+func xmlKey(entry xml.StartElement) (string, bool) {
+	for _, attr := range entry.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == "key" {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// xmlValue reads the text of an entry element up to and including its end element
+func xmlValue(d *xml.Decoder) (string, error) {
+	var value []byte
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			return "", fmt.Errorf("unexpected element <%s> in <%s>", token.Name.Local, xmlEntry)
+		case xml.CharData:
+			value = append(value, token...)
+		case xml.EndElement:
+			return string(value), nil
+		}
+	}
+}
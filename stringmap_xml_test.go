@@ -0,0 +1,107 @@
+package orderedmap_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestStringMap_MarshalXML(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("second", "2")
+	stringmap.Set("first", "1")
+	stringmap.Set(`a "quoted" <key>`, "Tom & Jerry <3")
+
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name  `xml:"config"`
+		Values  StringMap `xml:"values"`
+	}{Values: stringmap})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<config><values>` +
+		`<entry key="second">2</entry>` +
+		`<entry key="first">1</entry>` +
+		`<entry key="a &#34;quoted&#34; &lt;key&gt;">Tom &amp; Jerry &lt;3</entry>` +
+		`</values></config>`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestStringMap_MarshalXMLEmpty(t *testing.T) {
+	var stringmap StringMap
+
+	b, err := xml.Marshal(stringmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<StringMap></StringMap>`; string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestStringMap_UnmarshalXML(t *testing.T) {
+	input := `<values>
+	<entry key="key one">value 1</entry>
+	<!-- comments are ignored -->
+	<entry key="otherkey">Tom &amp; Jerry</entry>
+	<entry key="empty"/>
+	<entry key="key one">value 4</entry>
+</values>`
+
+	var stringmap StringMap
+	if err := xml.Unmarshal([]byte(input), &stringmap); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"key one", "value 4"},
+		{"otherkey", "Tom & Jerry"},
+		{"empty", ""},
+	})
+}
+
+func TestStringMap_UnmarshalXMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unexpected element", `<values><item key="a">b</item></values>`},
+		{"missing key", `<values><entry>b</entry></values>`},
+		{"nested element", `<values><entry key="a"><b>c</b></entry></values>`},
+		{"unexpected text", `<values>text<entry key="a">b</entry></values>`},
+		{"truncated", `<values><entry key="a">b</entry>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			if err := xml.Unmarshal([]byte(test.input), &stringmap); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestStringMap_XMLRoundTrip(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("z", "last")
+	stringmap.Set(`<a & "b">`, "Tom & Jerry <3")
+	stringmap.Set("", "")
+	stringmap.Set("a", " padded ")
+
+	b, err := xml.Marshal(stringmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded StringMap
+	if err := xml.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(stringmap) {
+		t.Errorf("expected %q to equal %q after round trip through %s", decoded.Pairs(), stringmap.Pairs(), b)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build !orderedmap_noyaml
+// +build !orderedmap_noyaml
+
+package orderedmap
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML support is kept in this file, which depends on gopkg.in/yaml.v3; build with the
+// orderedmap_noyaml tag to leave it out
+
+var _ yaml.Marshaler = (*StringMap)(nil)
+var _ yaml.Unmarshaler = (*StringMap)(nil)
+
+// MarshalYAML implements yaml.Marshaler
+// The map is encoded as a YAML mapping of string keys and values in order
+func (m StringMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{
+		Kind:    yaml.MappingNode,
+		Tag:     "!!map",
+		Content: make([]*yaml.Node, 0, 2*len(m.keys)),
+	}
+	for _, key := range m.keys {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: m.values[key]},
+		)
+	}
+
+	return node, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+// It reads a YAML mapping in document order, setting each pair; keys must be scalars and values strings
+func (m *StringMap) UnmarshalYAML(value *yaml.Node) error {
+	value = resolveYAML(value)
+	if value.Kind == yaml.DocumentNode && len(value.Content) == 1 {
+		value = resolveYAML(value.Content[0])
+	}
+	if value.Kind != yaml.MappingNode {
+		return errors.New("looking for mapping")
+	}
+
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, val := resolveYAML(value.Content[i]), resolveYAML(value.Content[i+1])
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: invalid key type", key.Line)
+		}
+		if val.Kind != yaml.ScalarNode || val.ShortTag() != "!!str" {
+			return fmt.Errorf("line %d: invalid value type %s for key %q", val.Line, val.ShortTag(), key.Value)
+		}
+
+		m.Set(key.Value, val.Value)
+	}
+
+	return nil
+}
+
+// resolveYAML returns the node an alias node refers to, or else node itself
+func resolveYAML(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// decodeYAML sets the entries of the YAML mapping in b, as used by DecodeAuto
+func (m *StringMap) decodeYAML(b []byte) error {
+	return yaml.Unmarshal(b, m)
+}
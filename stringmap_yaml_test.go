@@ -0,0 +1,157 @@
+//go:build !orderedmap_noyaml
+// +build !orderedmap_noyaml
+
+package orderedmap_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+	"gopkg.in/yaml.v3"
+)
+
+func TestStringMap_MarshalYAML(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("key one", "value 1")
+	stringmap.Set("otherkey", "true")
+	stringmap.Set("key3", "3")
+	stringmap.Set("", "multi\nline")
+
+	actually, err := yaml.Marshal(stringmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values which would otherwise be read back as another type are quoted
+	expected := `key one: value 1
+otherkey: "true"
+key3: "3"
+"": |-
+    multi
+    line
+`
+	if string(actually) != expected {
+		t.Errorf("expected yaml\n%s\ngot\n%s", expected, actually)
+	}
+}
+
+func TestStringMap_MarshalYAMLEmpty(t *testing.T) {
+	var stringmap StringMap
+	actually, err := yaml.Marshal(stringmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "{}\n"; string(actually) != expected {
+		t.Errorf("expected yaml %q, got %q", expected, actually)
+	}
+}
+
+func TestStringMap_MarshalYAMLNested(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("b", "2")
+	stringmap.Set("a", "1")
+
+	actually, err := yaml.Marshal(map[string]interface{}{"config": stringmap})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "config:\n    b: \"2\"\n    a: \"1\"\n"; string(actually) != expected {
+		t.Errorf("expected yaml %q, got %q", expected, actually)
+	}
+}
+
+func TestStringMap_UnmarshalYAML(t *testing.T) {
+	input := `
+# comments are ignored
+key one: value 1
+otherkey: "true"
+key2: 'a third value'
+key one: value 4
+anchored: &anchor shared
+aliased: *anchor
+`
+	var stringmap StringMap
+	if err := yaml.Unmarshal([]byte(input), &stringmap); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEntries(t, stringmap, []struct{ k, v string }{
+		{"key one", "value 4"},
+		{"otherkey", "true"},
+		{"key2", "a third value"},
+		{"anchored", "shared"},
+		{"aliased", "shared"},
+	})
+}
+
+func TestStringMap_UnmarshalYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"sequence", "- a\n- b\n"},
+		{"scalar", "hello\n"},
+		{"invalid value type", "number: 231\n"},
+		{"null value", "empty:\n"},
+		{"nested mapping", "nested:\n  a: b\n"},
+		{"invalid key type", "[a, b]: c\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			if err := yaml.Unmarshal([]byte(test.input), &stringmap); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestStringMap_YAMLRoundTrip(t *testing.T) {
+	var stringmap StringMap
+	stringmap.Set("z", "last")
+	stringmap.Set("yes", "no")
+	stringmap.Set("1.5", "null")
+	stringmap.Set("", "")
+	stringmap.Set("multi", "line\nvalue\n")
+
+	b, err := yaml.Marshal(stringmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded StringMap
+	if err := yaml.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(stringmap) {
+		t.Errorf("expected %q to equal %q after round trip through\n%s", decoded.Pairs(), stringmap.Pairs(), b)
+	}
+}
+
+func TestStringMap_DecodeAutoYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"mapping", "second: '2'\nurl: http://example.com/?a=b\n"},
+		{"document marker", "---\nsecond: '2'\nurl: http://example.com/?a=b\n"},
+		{"leading comment", "# settings\nsecond: \"2\"\nurl: http://example.com/?a=b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stringmap StringMap
+			stringmap.Set("url", "old")
+			if err := stringmap.DecodeAuto(strings.NewReader(test.input)); err != nil {
+				t.Fatal(err)
+			}
+
+			assertEntries(t, stringmap, []struct{ k, v string }{
+				{"url", "http://example.com/?a=b"},
+				{"second", "2"},
+			})
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package orderedmap
+
+import "sync"
+
+// SyncStringMap is a StringMap which is safe for concurrent use
+// Its zero value is an empty map ready to use. Methods returning multiple entries return copies taken
+// under the lock, so callers iterate a stable view without holding the lock for the duration
+type SyncStringMap struct {
+	mu sync.RWMutex
+	m  StringMap
+}
+
+// Set sets a key to a value
+// If a key already exists it is overwritten
+func (s *SyncStringMap) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m.Set(key, value)
+}
+
+// Update sets key to the value computed by fn from its current value, like StringMap.Update
+// The write lock is held while fn runs, so the read and the write happen as one operation; fn must not
+// call methods of s
+func (s *SyncStringMap) Update(key string, fn func(old string, existed bool) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m.Update(key, fn)
+}
+
+// ReplaceAll replaces the contents of the map by the given entries, in order, as one operation
+func (s *SyncStringMap) ReplaceAll(entries []Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m.ReplaceAll(entries)
+}
+
+// Value returns the value for key
+// ok is false if the key does not exist
+func (s *SyncStringMap) Value(key string) (value string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.m.Value(key)
+}
+
+// Has reports whether key exists
+func (s *SyncStringMap) Has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.m.Has(key)
+}
+
+// Delete removes a key from the map, preserving the order of the remaining keys
+func (s *SyncStringMap) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m.Delete(key)
+}
+
+// Len returns the number of entries
+func (s *SyncStringMap) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.m.Len()
+}
+
+// Keys returns a copy of the keys in order
+func (s *SyncStringMap) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.m.Keys()
+}
+
+// Snapshot returns a copy of the map which shares no storage with s
+func (s *SyncStringMap) Snapshot() StringMap {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.m.Clone()
+}
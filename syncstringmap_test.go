@@ -0,0 +1,112 @@
+package orderedmap_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+func TestSyncStringMap(t *testing.T) {
+	var syncmap SyncStringMap
+	syncmap.Set("second", "2")
+	syncmap.Set("first", "1")
+	syncmap.Set("third", "3")
+	syncmap.Delete("first")
+
+	if value, ok := syncmap.Value("second"); !ok || value != "2" {
+		t.Errorf("expected value 2, got %q, %t", value, ok)
+	}
+	if syncmap.Has("first") {
+		t.Errorf("expected deleted key to be absent")
+	}
+	if syncmap.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", syncmap.Len())
+	}
+	if keys := fmt.Sprint(syncmap.Keys()); keys != "[second third]" {
+		t.Errorf("expected keys [second third], got %s", keys)
+	}
+
+	syncmap.Update("second", func(old string, existed bool) string { return old + "2" })
+	if value, _ := syncmap.Value("second"); value != "22" {
+		t.Errorf("expected updated value 22, got %q", value)
+	}
+	syncmap.ReplaceAll([]Entry{{Key: "second", Value: "2"}, {Key: "third", Value: "3"}})
+
+	snapshot := syncmap.Snapshot()
+	syncmap.Set("fourth", "4")
+	assertEntries(t, snapshot, []struct{ k, v string }{
+		{"second", "2"},
+		{"third", "3"},
+	})
+}
+
+func TestSyncStringMap_Concurrent(t *testing.T) {
+	var syncmap SyncStringMap
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("key%d", i%50)
+				switch (g + i) % 6 {
+				case 0:
+					syncmap.Set(key, "value")
+				case 1:
+					syncmap.Delete(key)
+				case 2:
+					syncmap.Value(key)
+					syncmap.Has(key)
+					syncmap.Len()
+				case 3:
+					for _, k := range syncmap.Keys() {
+						_ = k
+					}
+					syncmap.Snapshot()
+				case 4:
+					syncmap.Update("counter", func(old string, _ bool) string {
+						n, _ := strconv.Atoi(old)
+						return strconv.Itoa(n + 1)
+					})
+				case 5:
+					if i%100 == 5 {
+						syncmap.ReplaceAll([]Entry{{Key: key, Value: "replaced"}})
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if keys := syncmap.Keys(); len(keys) != syncmap.Len() {
+		t.Errorf("expected %d keys, got %d", syncmap.Len(), len(keys))
+	}
+}
+
+func TestSyncStringMap_UpdateConcurrent(t *testing.T) {
+	var syncmap SyncStringMap
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				syncmap.Update("counter", func(old string, _ bool) string {
+					n, _ := strconv.Atoi(old)
+					return strconv.Itoa(n + 1)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	// No increment is lost between reading and writing the value
+	if value, _ := syncmap.Value("counter"); value != "800" {
+		t.Errorf("expected counter 800, got %s", value)
+	}
+}
@@ -0,0 +1,163 @@
+package orderedmap
+
+import (
+	"encoding/json"
+	"time"
+)
+
+var _ json.Marshaler = (*TimedStringMap)(nil)
+var _ json.Unmarshaler = (*TimedStringMap)(nil)
+
+// TimestampPolicy determines which time a TimedStringMap records for a key
+type TimestampPolicy int
+
+const (
+	// RecordInsertion records the time a key was first set
+	RecordInsertion TimestampPolicy = iota
+	// RecordUpdate records the time of the latest Set of a key
+	RecordUpdate
+)
+
+// TimedStringMap is an ordered map of strings which records the time at which its keys are set
+// The entries are only modified through its own methods, which keep a time recorded for every key;
+// Map returns a copy to use with the other operations of StringMap
+type TimedStringMap struct {
+	// Policy selects whether updating an existing key refreshes its timestamp
+	Policy TimestampPolicy
+	// Clock returns the current time; time.Now is used when nil
+	Clock func() time.Time
+
+	entries StringMap
+	times   map[string]time.Time
+}
+
+// Set sets a key to a value and records the time according to Policy
+// If a key already exists it is overwritten
+func (m *TimedStringMap) Set(key, value string) {
+	_, exists := m.entries.values[key]
+	m.entries.Set(key, value)
+
+	if m.times == nil {
+		m.times = make(map[string]time.Time)
+	}
+	if !exists || m.Policy == RecordUpdate {
+		m.times[key] = m.now()
+	}
+}
+
+// Update sets key to the value computed by fn from its current value, like StringMap.Update
+// The time is recorded like Set does
+func (m *TimedStringMap) Update(key string, fn func(old string, existed bool) string) {
+	old, existed := m.entries.values[key]
+	m.Set(key, fn(old, existed))
+}
+
+// Delete removes a key and its recorded time, preserving the order of the remaining keys
+func (m *TimedStringMap) Delete(key string) {
+	m.entries.Delete(key)
+	delete(m.times, key)
+}
+
+// Pop removes a key and its recorded time and returns its value
+// ok is false if the key does not exist, in which case the map is unchanged
+func (m *TimedStringMap) Pop(key string) (value string, ok bool) {
+	value, ok = m.entries.Pop(key)
+	delete(m.times, key)
+
+	return value, ok
+}
+
+// Clear removes all entries and their recorded times
+func (m *TimedStringMap) Clear() {
+	m.entries.Clear()
+	for key := range m.times {
+		delete(m.times, key)
+	}
+}
+
+// Value returns the value for key
+// ok is false if the key does not exist
+func (m TimedStringMap) Value(key string) (string, bool) {
+	return m.entries.Value(key)
+}
+
+// Has reports whether key exists
+func (m TimedStringMap) Has(key string) bool {
+	return m.entries.Has(key)
+}
+
+// Len returns the number of entries
+func (m TimedStringMap) Len() int {
+	return m.entries.Len()
+}
+
+// Keys returns the keys in order
+func (m TimedStringMap) Keys() []string {
+	return m.entries.Keys()
+}
+
+// Map returns a copy of the entries, in order
+// Modifying the copy does not affect m
+func (m TimedStringMap) Map() StringMap {
+	return m.entries.Clone()
+}
+
+// SetTime returns the time recorded for key
+func (m TimedStringMap) SetTime(key string) (time.Time, bool) {
+	t, ok := m.times[key]
+	return t, ok
+}
+
+// ExpireOlderThan removes all entries which were recorded more than d ago
+// It returns the number of entries removed. Every entry has a recorded time, including those added
+// by UnmarshalJSON or Update, so none is exempt from expiry
+func (m *TimedStringMap) ExpireOlderThan(d time.Duration) int {
+	deadline := m.now().Add(-d)
+	return m.entries.retain(func(key, _ string) bool {
+		if m.times[key].Before(deadline) {
+			delete(m.times, key)
+			return false
+		}
+		return true
+	})
+}
+
+// EntriesBetween returns a new map of the entries recorded within start and end, both inclusive,
+// in order
+func (m TimedStringMap) EntriesBetween(start, end time.Time) StringMap {
+	var between StringMap
+	for _, key := range m.entries.keys {
+		if t := m.times[key]; !t.Before(start) && !t.After(end) {
+			between.Set(key, m.entries.values[key])
+		}
+	}
+
+	return between
+}
+
+// MarshalJSON implements json.Marshaler, encoding the entries like StringMap does
+func (m TimedStringMap) MarshalJSON() ([]byte, error) {
+	return m.entries.MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+// The entries are decoded like StringMap does and set in order, recording the time like Set does
+func (m *TimedStringMap) UnmarshalJSON(b []byte) error {
+	var decoded StringMap
+	if err := decoded.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	for _, key := range decoded.keys {
+		m.Set(key, decoded.values[key])
+	}
+	return nil
+}
+
+// now returns the current time according to Clock
+func (m TimedStringMap) now() time.Time {
+	if m.Clock != nil {
+		return m.Clock()
+	}
+	return time.Now()
+}
@@ -0,0 +1,177 @@
+package orderedmap_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/ferdypruis/orderedmap"
+)
+
+// fakeClock returns a clock which advances one second on every call
+func fakeClock() func() time.Time {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func TestTimedStringMap_SetTime(t *testing.T) {
+	tests := []struct {
+		policy   TimestampPolicy
+		expected time.Time
+	}{
+		{RecordInsertion, time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{RecordUpdate, time.Date(2020, 1, 1, 0, 0, 3, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		timedmap := TimedStringMap{Policy: test.policy, Clock: fakeClock()}
+		timedmap.Set("first", "1")
+		timedmap.Set("second", "2")
+		timedmap.Set("first", "3")
+
+		if actually, ok := timedmap.SetTime("first"); !ok || !actually.Equal(test.expected) {
+			t.Errorf("policy %d: expected time %s, got %s, %t", test.policy, test.expected, actually, ok)
+		}
+
+		// Ordering is retained like a regular StringMap
+		assertEntries(t, timedmap.Map(), []struct{ k, v string }{
+			{"first", "3"},
+			{"second", "2"},
+		})
+	}
+}
+
+func TestTimedStringMap_SetTimeMissing(t *testing.T) {
+	var timedmap TimedStringMap
+	if _, ok := timedmap.SetTime("notexist"); ok {
+		t.Errorf("expected no time for key on zero value")
+	}
+
+	before := time.Now()
+	timedmap.Set("first", "1")
+	if actually, ok := timedmap.SetTime("first"); !ok || actually.Before(before) {
+		t.Errorf("expected a time after %s, got %s, %t", before, actually, ok)
+	}
+	if _, ok := timedmap.SetTime("notexist"); ok {
+		t.Errorf("expected no time for key %q", "notexist")
+	}
+}
+
+func TestTimedStringMap_Mutators(t *testing.T) {
+	timedmap := TimedStringMap{Policy: RecordUpdate, Clock: fakeClock()}
+	if err := json.Unmarshal([]byte(`{"first":"1","second":"2","third":"3"}`), &timedmap); err != nil {
+		t.Fatal(err)
+	} // 00:01 - 00:03
+	timedmap.Update("first", func(old string, _ bool) string { return old + "1" }) // 00:04
+	timedmap.Update("fourth", func(string, bool) string { return "4" })            // 00:05
+	timedmap.Delete("second")
+	if value, ok := timedmap.Pop("third"); !ok || value != "3" {
+		t.Errorf("expected to pop value 3, got %q, %t", value, ok)
+	}
+
+	times := map[string]int{"first": 4, "second": -1, "third": -1, "fourth": 5}
+	for key, second := range times {
+		actually, ok := timedmap.SetTime(key)
+		if second < 0 && ok {
+			t.Errorf("expected no time for removed key %q, got %s", key, actually)
+		} else if second >= 0 && (!ok || actually.Second() != second) {
+			t.Errorf("expected time 00:%02d for key %q, got %s, %t", second, key, actually, ok)
+		}
+	}
+	assertEntries(t, timedmap.Map(), []struct{ k, v string }{
+		{"first", "11"},
+		{"fourth", "4"},
+	})
+
+	b, err := json.Marshal(timedmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"first":"11","fourth":"4"}`; string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	// Modifying the copy returned by Map does not affect the timed map
+	entries := timedmap.Map()
+	entries.Set("fifth", "5")
+	if timedmap.Has("fifth") {
+		t.Errorf("expected copy to be independent")
+	}
+
+	timedmap.Clear()
+	if _, ok := timedmap.SetTime("first"); ok || timedmap.Len() != 0 {
+		t.Errorf("expected no entries or times after Clear")
+	}
+}
+
+func TestTimedStringMap_ExpireOlderThan(t *testing.T) {
+	timedmap := TimedStringMap{Clock: fakeClock()}
+	timedmap.Set("first", "1")  // 00:01
+	timedmap.Set("second", "2") // 00:02
+	timedmap.Set("third", "3")  // 00:03
+	timedmap.Set("fourth", "4") // 00:04
+
+	// Asking for the time advances the clock to 00:05, so 00:03 is the deadline
+	if removed := timedmap.ExpireOlderThan(2 * time.Second); removed != 2 {
+		t.Errorf("expected 2 entries to be removed, got %d", removed)
+	}
+
+	assertEntries(t, timedmap.Map(), []struct{ k, v string }{
+		{"third", "3"},
+		{"fourth", "4"},
+	})
+	if _, ok := timedmap.SetTime("first"); ok {
+		t.Errorf("expected no time for expired key %q", "first")
+	}
+
+	// Setting an expired key again records a new time
+	timedmap.Set("first", "5") // 00:06
+	if actually, _ := timedmap.SetTime("first"); actually.Second() != 6 {
+		t.Errorf("expected new time for key %q, got %s", "first", actually)
+	}
+}
+
+func TestTimedStringMap_EntriesBetween(t *testing.T) {
+	timedmap := TimedStringMap{Policy: RecordUpdate, Clock: fakeClock()}
+	timedmap.Set("first", "1")  // 00:01
+	timedmap.Set("second", "2") // 00:02
+	timedmap.Set("third", "3")  // 00:03
+	timedmap.Set("fourth", "4") // 00:04
+	timedmap.Set("first", "5")  // 00:05
+
+	start := time.Date(2020, 1, 1, 0, 0, 2, 0, time.UTC)
+	end := time.Date(2020, 1, 1, 0, 0, 4, 0, time.UTC)
+	assertEntries(t, timedmap.EntriesBetween(start, end), []struct{ k, v string }{
+		{"second", "2"},
+		{"third", "3"},
+		{"fourth", "4"},
+	})
+
+	if timedmap.Len() != 4 {
+		t.Errorf("expected receiver to keep 4 items, got %d", timedmap.Len())
+	}
+}
+
+func TestTimedStringMap_ExpireOlderThanUnmarshaled(t *testing.T) {
+	timedmap := TimedStringMap{Clock: fakeClock()}
+	if err := json.Unmarshal([]byte(`{"first":"1","second":"2"}`), &timedmap); err != nil {
+		t.Fatal(err)
+	} // 00:01 - 00:02
+	timedmap.Update("third", func(string, bool) string { return "3" }) // 00:03
+
+	// Asking for the time advances the clock to 00:04, so 00:02 is the deadline
+	if removed := timedmap.ExpireOlderThan(2 * time.Second); removed != 1 {
+		t.Errorf("expected 1 entry to be removed, got %d", removed)
+	}
+	assertEntries(t, timedmap.Map(), []struct{ k, v string }{
+		{"second", "2"},
+		{"third", "3"},
+	})
+
+	// Eventually everything expires, however it was added
+	if removed := timedmap.ExpireOlderThan(0); removed != 2 || timedmap.Len() != 0 {
+		t.Errorf("expected 2 entries to be removed, got %d leaving %d", removed, timedmap.Len())
+	}
+}